@@ -7,7 +7,6 @@ import (
 	"fmt"
 	"math"
 	"net"
-	"sort"
 	"sync"
 	"time"
 
@@ -20,9 +19,11 @@ import (
 	tls "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
 	"google.golang.org/grpc"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/known/structpb"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/util/protomarshal"
 	"istio.io/istio/pkg/util/sets"
@@ -61,6 +62,24 @@ type Config struct {
 	Delta   bool
 
 	StoreResponses bool
+
+	// Nack configures rejecting some responses. By default, all responses are ACKed.
+	Nack NackConfig
+
+	// Delay configures a processing delay for each response, to model a slow client.
+	Delay ProcessingDelay
+
+	// Validate enables validation of each resource received, and of references between resources.
+	Validate bool
+
+	// OnDemand configures loading clusters on demand, rather than subscribing to all of them.
+	OnDemand OnDemandConfig
+
+	// Ztunnel configures address subscriptions for ztunnel nodes.
+	Ztunnel ZtunnelConfig
+
+	// RecordFile, if set, is a file to record all responses to. See ReadRecording.
+	RecordFile string
 }
 
 // ADSC implements a basic client for ADS, for use in stress tests and tools
@@ -70,12 +89,16 @@ type ADSC struct {
 	// Stream is the GRPC connection stream, allowing direct GRPC send operations.
 	// Set after Dial is called.
 	stream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesClient
+	// recv receives from the stream, applying any processing delay
+	recv  func() (*discovery.DiscoveryResponse, error)
+	delay ProcessingDelay
 
 	conn *grpc.ClientConn
 
 	// NodeID is the node identity sent to Pilot.
-	nodeID string
-	node   *core.Node
+	nodeID   string
+	node     *core.Node
+	nodeType string
 
 	done chan error
 
@@ -98,6 +121,10 @@ type ADSC struct {
 	mutex   sync.Mutex
 	watches map[string]Watch
 	store   bool
+	nacker  *nacker
+	// validator checks received config, if enabled
+	validator *validator
+	recorder  *recorder
 }
 
 func (a *ADSC) Updates() chan string {
@@ -118,7 +145,8 @@ type Responses struct {
 }
 
 type Watch struct {
-	resources   []string
+	// resources we are subscribed to. Empty for wildcard types.
+	resources   sets.String
 	lastNonce   string
 	lastVersion string
 }
@@ -152,6 +180,9 @@ func Dial(url string, opts *Config) (ADSClient, error) {
 	if opts.Delta {
 		return DialDelta(url, opts)
 	}
+	if opts.OnDemand.Enabled() {
+		scope.Warnf("on-demand clusters require delta XDS, subscribing to all clusters")
+	}
 	adsc := &ADSC{
 		done:    make(chan error),
 		updates: make(chan string, 100),
@@ -168,6 +199,9 @@ func Dial(url string, opts *Config) (ADSClient, error) {
 		url:      url,
 		store:    opts.StoreResponses,
 		ctx:      opts.Context,
+		nodeType: opts.NodeType,
+		nacker:   newNacker(opts.Nack),
+		delay:    opts.Delay,
 	}
 
 	adsc.Metadata = opts.Meta
@@ -175,12 +209,21 @@ func Dial(url string, opts *Config) (ADSClient, error) {
 	adsc.nodeID = fmt.Sprintf("%s~%s~%s.%s~%s.svc.cluster.local", opts.NodeType, opts.IP,
 		opts.Workload, opts.Namespace, opts.Namespace)
 	adsc.node = makeNode(adsc.nodeID, adsc.Metadata)
+	adsc.validator = newValidator(opts.Validate, adsc.nodeID, opts.NodeType, false)
+	rec, err := newRecorder(opts.RecordFile)
+	if err != nil {
+		return nil, fmt.Errorf("recorder: %v", err)
+	}
+	adsc.recorder = rec
 	if dumpScope.DebugEnabled() {
 		n, _ := protomarshal.ToJSONWithIndent(adsc.node, "  ")
 		dumpScope.Debugf("constructed node: %v", n)
 	}
-	err := adsc.Run()
-	return adsc, err
+	if err := adsc.Run(); err != nil {
+		adsc.Close()
+		return adsc, err
+	}
+	return adsc, nil
 }
 
 // Returns a private IP address, or unspecified IP (0.0.0.0) if no IP is available
@@ -205,6 +248,8 @@ func getPrivateIPIfAvailable() net.IP {
 
 // Close the stream.
 func (a *ADSC) Close() {
+	a.validator.stop()
+	a.recorder.close()
 	a.mutex.Lock()
 	if a.stream != nil {
 		_ = a.stream.CloseSend()
@@ -230,13 +275,14 @@ func (a *ADSC) Run() error {
 		return fmt.Errorf("stream: %v", err)
 	}
 	a.stream = edsstr
+	a.recv = slowReceiver(a.ctx, a.delay, edsstr.Recv)
 	go a.handleRecv()
 	return nil
 }
 
 func (a *ADSC) handleRecv() {
 	for {
-		msg, err := a.stream.Recv()
+		msg, err := a.recv()
 		if err != nil {
 			scope.Infof("Connection closed for %v: %v", a.nodeID, err)
 			a.Close()
@@ -245,6 +291,8 @@ func (a *ADSC) handleRecv() {
 			return
 		}
 		scope.Debugf("got message for type %v", msg.TypeUrl)
+		a.recorder.record(msg)
+		recordResponse(a.nodeType, msg.TypeUrl, proto.Size(msg), len(msg.Resources))
 
 		listeners := []*listener.Listener{}
 		clusters := []*cluster.Cluster{}
@@ -252,8 +300,7 @@ func (a *ADSC) handleRecv() {
 		eds := []*endpoint.ClusterLoadAssignment{}
 		secrets := []*tls.Secret{}
 		ecds := []*core.TypedExtensionConfig{}
-		// TODO re-enable use of names. For now its skipped
-		names := []string{}
+		allNames := make([]string, 0, len(msg.Resources))
 		resp := map[string]proto.Message{}
 		for _, rsc := range msg.Resources {
 			valBytes := rsc.Value
@@ -262,6 +309,7 @@ func (a *ADSC) handleRecv() {
 				ll := &listener.Listener{}
 				_ = proto.Unmarshal(valBytes, ll)
 				listeners = append(listeners, ll)
+				allNames = append(allNames, ll.Name)
 				if a.store {
 					resp[ll.Name] = ll
 				}
@@ -270,6 +318,7 @@ func (a *ADSC) handleRecv() {
 				ll := &cluster.Cluster{}
 				_ = proto.Unmarshal(valBytes, ll)
 				clusters = append(clusters, ll)
+				allNames = append(allNames, ll.Name)
 				if a.store {
 					resp[ll.Name] = ll
 				}
@@ -278,7 +327,7 @@ func (a *ADSC) handleRecv() {
 				ll := &endpoint.ClusterLoadAssignment{}
 				_ = proto.Unmarshal(valBytes, ll)
 				eds = append(eds, ll)
-				names = append(names, ll.ClusterName)
+				allNames = append(allNames, ll.ClusterName)
 				if a.store {
 					resp[ll.ClusterName] = ll
 				}
@@ -287,7 +336,7 @@ func (a *ADSC) handleRecv() {
 				ll := &route.RouteConfiguration{}
 				_ = proto.Unmarshal(valBytes, ll)
 				routes = append(routes, ll)
-				names = append(names, ll.Name)
+				allNames = append(allNames, ll.Name)
 				if a.store {
 					resp[ll.Name] = ll
 				}
@@ -296,7 +345,7 @@ func (a *ADSC) handleRecv() {
 				ll := &tls.Secret{}
 				_ = proto.Unmarshal(valBytes, ll)
 				secrets = append(secrets, ll)
-				names = append(names, ll.Name)
+				allNames = append(allNames, ll.Name)
 				if a.store {
 					resp[ll.Name] = ll
 				}
@@ -305,14 +354,32 @@ func (a *ADSC) handleRecv() {
 				ll := &core.TypedExtensionConfig{}
 				_ = proto.Unmarshal(valBytes, ll)
 				ecds = append(ecds, ll)
-				names = append(names, ll.Name)
+				allNames = append(allNames, ll.Name)
 				if a.store {
 					resp[ll.Name] = ll
 				}
 			}
 		}
 
+		if detail := a.nacker.shouldNack(msg.TypeUrl, allNames); detail != nil {
+			// Rejected config is not applied, so we do not follow any references in it.
+			a.mutex.Lock()
+			a.nack(msg, detail)
+			if nackCompletesInit(msg.TypeUrl) {
+				a.requestInitialListeners()
+			}
+			a.mutex.Unlock()
+			select {
+			case a.updates <- v3.GetMetricType(msg.TypeUrl):
+			default:
+			}
+			continue
+		}
+
+		a.validator.observe(msg.TypeUrl, msg.Resources, nil, true)
+
 		a.mutex.Lock()
+		a.checkUnrequested(msg.TypeUrl, allNames)
 		switch msg.TypeUrl {
 		case resource.ListenerType:
 			a.responses.Listeners = resp
@@ -327,7 +394,7 @@ func (a *ADSC) handleRecv() {
 		case resource.ExtensionConfigType:
 			a.responses.Extensions = resp
 		}
-		a.ack(msg, names)
+		a.ack(msg)
 		a.mutex.Unlock()
 
 		switch msg.TypeUrl {
@@ -357,7 +424,7 @@ func getFilterChains(l *listener.Listener) []*listener.FilterChain {
 
 // nolint: staticcheck
 func (a *ADSC) handleLDS(ll []*listener.Listener) {
-	routes := []string{}
+	routes := sets.New[string]()
 	extensions := sets.New[string]()
 	sockets := []*core.TransportSocket{}
 	secrets := sets.New[string]()
@@ -368,7 +435,7 @@ func (a *ADSC) handleLDS(ll []*listener.Listener) {
 					hcm := &hcm.HttpConnectionManager{}
 					_ = f.GetTypedConfig().UnmarshalTo(hcm)
 					if r := hcm.GetRds().GetRouteConfigName(); r != "" {
-						routes = append(routes, r)
+						routes.Insert(r)
 					}
 					for _, f := range hcm.GetHttpFilters() {
 						if f.GetConfigDiscovery() != nil {
@@ -389,17 +456,11 @@ func (a *ADSC) handleLDS(ll []*listener.Listener) {
 		}
 	}
 	for _, s := range sockets {
-		dtl := &tls.DownstreamTlsContext{}
-		_ = s.GetTypedConfig().UnmarshalTo(dtl)
-		secrets.Insert(dtl.GetCommonTlsContext().GetCombinedValidationContext().GetValidationContextSdsSecretConfig().GetName())
-		for _, s := range dtl.GetCommonTlsContext().GetTlsCertificateSdsSecretConfigs() {
-			secrets.Insert(s.GetName())
-		}
+		secrets.InsertAll(transportSocketSecrets(s)...)
 	}
 	secrets.Delete("")
 	secrets.Delete("ROOTCA")
 	secrets.Delete("default")
-	sort.Strings(routes)
 
 	if dumpScope.DebugEnabled() {
 		for i, l := range ll {
@@ -415,13 +476,8 @@ func (a *ADSC) handleLDS(ll []*listener.Listener) {
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
-	if len(secrets) > 0 {
-		a.handleResourceUpdate(resource.SecretType, sets.SortedList(secrets))
-	}
-
-	if len(extensions) > 0 {
-		a.handleResourceUpdate(resource.ExtensionConfigType, sets.SortedList(extensions))
-	}
+	a.handleResourceUpdate(resource.SecretType, secrets)
+	a.handleResourceUpdate(resource.ExtensionConfigType, extensions)
 	a.handleResourceUpdate(resource.RouteType, routes)
 
 	select {
@@ -430,27 +486,64 @@ func (a *ADSC) handleLDS(ll []*listener.Listener) {
 	}
 }
 
-func (a *ADSC) handleResourceUpdate(typeUrl string, resources []string) {
-	if !listEqual(a.watches[typeUrl].resources, resources) {
-		scope.Debugf("%v type resources changed: %v -> %v", typeUrl, a.watches[typeUrl].resources, resources)
-		watch := a.watches[typeUrl]
-		watch.resources = resources
-		a.watches[typeUrl] = watch
-		a.request(typeUrl, watch)
+// transportSocketSecrets returns the names of SDS secrets referenced by a TLS transport socket.
+func transportSocketSecrets(ts *core.TransportSocket) []string {
+	var ctx *tls.CommonTlsContext
+	switch ts.GetTypedConfig().GetTypeUrl() {
+	case "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.DownstreamTlsContext":
+		dtl := &tls.DownstreamTlsContext{}
+		_ = ts.GetTypedConfig().UnmarshalTo(dtl)
+		ctx = dtl.GetCommonTlsContext()
+	case "type.googleapis.com/envoy.extensions.transport_sockets.tls.v3.UpstreamTlsContext":
+		utl := &tls.UpstreamTlsContext{}
+		_ = ts.GetTypedConfig().UnmarshalTo(utl)
+		ctx = utl.GetCommonTlsContext()
+	default:
+		return nil
+	}
+	names := []string{
+		ctx.GetCombinedValidationContext().GetValidationContextSdsSecretConfig().GetName(),
+		ctx.GetValidationContextSdsSecretConfig().GetName(),
+	}
+	for _, s := range ctx.GetTlsCertificateSdsSecretConfigs() {
+		names = append(names, s.GetName())
 	}
+	return names
 }
 
-// listEqual checks that two lists contain all the same elements
-func listEqual(a []string, b []string) bool {
-	if len(a) != len(b) {
-		return false
-	}
-	for i := range a {
-		if a[i] != b[i] {
-			return false
+// handleResourceUpdate sets the resources we are subscribed to for the type. As this is SotW, any change (including
+// unsubscribing from removed resources) is a new request with the full set. Must be called with the mutex held.
+func (a *ADSC) handleResourceUpdate(typeUrl string, resources sets.String) {
+	watch := a.watches[typeUrl]
+	if watch.resources.Equals(resources) {
+		return
+	}
+	added := resources.Difference(watch.resources)
+	removed := watch.resources.Difference(resources)
+	scope.Debugf("%v type resources changed: +%v -%v", typeUrl, sets.SortedList(added), sets.SortedList(removed))
+	watch.resources = resources
+	a.watches[typeUrl] = watch
+	a.request(typeUrl, watch)
+}
+
+// checkUnrequested reports, and returns, any resources the server sent that we did not subscribe to. Wildcard types
+// are not checked. Must be called with the mutex held.
+func (a *ADSC) checkUnrequested(typeUrl string, names []string) []string {
+	if isWildcardTypeURL(typeUrl) {
+		return nil
+	}
+	watch := a.watches[typeUrl]
+	unrequested := []string{}
+	for _, n := range names {
+		if !watch.resources.Contains(n) {
+			unrequested = append(unrequested, n)
 		}
 	}
-	return true
+	if len(unrequested) > 0 {
+		scope.Warnf("%v: received %d unrequested %v resources: %v", a.nodeID, len(unrequested), v3.GetShortType(typeUrl), unrequested)
+		recordUnrequested(a.nodeType, typeUrl, len(unrequested))
+	}
+	return unrequested
 }
 
 // compact representations, for simplified debugging/testing
@@ -481,7 +574,7 @@ type Endpoint struct {
 }
 
 func (a *ADSC) handleCDS(ll []*cluster.Cluster) {
-	cn := []string{}
+	cn := sets.New[string]()
 	for _, c := range ll {
 		// nolint
 		switch v := c.ClusterDiscoveryType.(type) {
@@ -491,11 +584,8 @@ func (a *ADSC) handleCDS(ll []*cluster.Cluster) {
 			}
 		}
 
-		cn = append(cn, c.Name)
+		cn.Insert(c.Name)
 	}
-	sort.Strings(cn)
-
-	a.handleResourceUpdate(resource.EndpointType, cn)
 
 	if dumpScope.DebugEnabled() {
 		for i, c := range ll {
@@ -510,6 +600,17 @@ func (a *ADSC) handleCDS(ll []*cluster.Cluster) {
 
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
+	a.handleResourceUpdate(resource.EndpointType, cn)
+	a.requestInitialListeners()
+
+	select {
+	case a.updates <- "cds":
+	default:
+	}
+}
+
+// requestInitialListeners requests listeners, if not yet requested. Must be called with the mutex held.
+func (a *ADSC) requestInitialListeners() {
 	if !a.InitialLoad {
 		// first load - Envoy loads listeners after endpoints
 		_ = a.send(&discovery.DiscoveryRequest{
@@ -518,11 +619,6 @@ func (a *ADSC) handleCDS(ll []*cluster.Cluster) {
 		}, ReasonInit)
 		a.InitialLoad = true
 	}
-
-	select {
-	case a.updates <- "cds":
-	default:
-	}
 }
 
 func makeNode(id string, metadata interface{}) *core.Node {
@@ -667,6 +763,7 @@ func (a *ADSC) Wait(update string, to time.Duration) (string, error) {
 
 func (a *ADSC) send(dr *discovery.DiscoveryRequest, reason string) error {
 	scope.Debugf("send message for type %v (%v) for %v", dr.TypeUrl, reason, dr.ResourceNames)
+	recordRequest(a.nodeType, dr.TypeUrl, reason)
 	return a.stream.Send(dr)
 }
 
@@ -684,6 +781,7 @@ func (a *ADSC) Watch() {
 
 const (
 	ReasonAck     = "ack"
+	ReasonNack    = "nack"
 	ReasonRequest = "request"
 	ReasonInit    = "init"
 )
@@ -694,24 +792,37 @@ func (a *ADSC) request(typeUrl string, watch Watch) {
 		TypeUrl:       typeUrl,
 		Node:          a.node,
 		VersionInfo:   watch.lastVersion,
-		ResourceNames: watch.resources,
+		ResourceNames: sets.SortedList(watch.resources),
 	}, ReasonRequest)
 }
 
-func (a *ADSC) ack(msg *discovery.DiscoveryResponse, names []string) {
+// ack accepts the response. As with Envoy, the ACK includes all subscribed resources, not just those in the response.
+func (a *ADSC) ack(msg *discovery.DiscoveryResponse) {
 	watch := a.watches[msg.TypeUrl]
 	watch.lastNonce = msg.Nonce
 	watch.lastVersion = msg.VersionInfo
 	a.watches[msg.TypeUrl] = watch
-	// Incremental EDS can send partial endpoints, but in ack envoy should ack for all.
-	if msg.TypeUrl == resource.EndpointType {
-		names = watch.resources
-	}
 	_ = a.send(&discovery.DiscoveryRequest{
 		ResponseNonce: msg.Nonce,
 		TypeUrl:       msg.TypeUrl,
 		Node:          a.node,
 		VersionInfo:   msg.VersionInfo,
-		ResourceNames: names,
+		ResourceNames: sets.SortedList(watch.resources),
 	}, ReasonAck)
 }
+
+// nack rejects the response. As with Envoy, the version of the last accepted response is sent.
+func (a *ADSC) nack(msg *discovery.DiscoveryResponse, detail *status.Status) {
+	watch := a.watches[msg.TypeUrl]
+	watch.lastNonce = msg.Nonce
+	a.watches[msg.TypeUrl] = watch
+	scope.Debugf("rejecting %v version %v: %v", msg.TypeUrl, msg.VersionInfo, detail.Message)
+	_ = a.send(&discovery.DiscoveryRequest{
+		ResponseNonce: msg.Nonce,
+		TypeUrl:       msg.TypeUrl,
+		Node:          a.node,
+		VersionInfo:   watch.lastVersion,
+		ResourceNames: sets.SortedList(watch.resources),
+		ErrorDetail:   detail,
+	}, ReasonNack)
+}
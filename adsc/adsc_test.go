@@ -0,0 +1,142 @@
+package adsc
+
+import (
+	"slices"
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// fakeStream records the requests sent on it.
+type fakeStream struct {
+	discovery.AggregatedDiscoveryService_StreamAggregatedResourcesClient
+	sent []*discovery.DiscoveryRequest
+}
+
+func (f *fakeStream) Send(r *discovery.DiscoveryRequest) error {
+	f.sent = append(f.sent, r)
+	return nil
+}
+
+// last returns the last request sent, and clears the sent requests.
+func (f *fakeStream) last() *discovery.DiscoveryRequest {
+	if len(f.sent) == 0 {
+		return nil
+	}
+	r := f.sent[len(f.sent)-1]
+	f.sent = nil
+	return r
+}
+
+// sameNames compares resource names, treating nil and empty as equal.
+func sameNames(a, b []string) bool {
+	return slices.Equal(a, b) || len(a) == 0 && len(b) == 0
+}
+
+func newTestADSC() (*ADSC, *fakeStream) {
+	s := &fakeStream{}
+	return &ADSC{nodeID: "node", nodeType: "sidecar", stream: s, watches: map[string]Watch{}}, s
+}
+
+func TestHandleResourceUpdate(t *testing.T) {
+	a, s := newTestADSC()
+	steps := []struct {
+		name      string
+		resources []string
+		// want is the resources requested, or nil if no request is expected
+		want []string
+	}{
+		{name: "subscribe", resources: []string{"b", "a"}, want: []string{"a", "b"}},
+		{name: "unchanged", resources: []string{"a", "b"}, want: nil},
+		{name: "unsubscribe", resources: []string{"a"}, want: []string{"a"}},
+		{name: "resubscribe", resources: []string{"a", "b"}, want: []string{"a", "b"}},
+		{name: "unsubscribe all", resources: []string{}, want: []string{}},
+	}
+	for _, st := range steps {
+		a.handleResourceUpdate(v3.RouteType, sets.New(st.resources...))
+		got := s.last()
+		if st.want == nil {
+			if got != nil {
+				t.Fatalf("%v: got request %v, want none", st.name, got.ResourceNames)
+			}
+			continue
+		}
+		if got == nil {
+			t.Fatalf("%v: got no request, want %v", st.name, st.want)
+		}
+		// SotW requests always carry the full set of subscriptions
+		if !sameNames(got.ResourceNames, st.want) {
+			t.Fatalf("%v: got %v, want %v", st.name, got.ResourceNames, st.want)
+		}
+	}
+}
+
+func TestCheckUnrequested(t *testing.T) {
+	tests := []struct {
+		name       string
+		typ        string
+		subscribed []string
+		received   []string
+		want       []string
+	}{
+		{name: "all requested", typ: v3.RouteType, subscribed: []string{"a", "b"}, received: []string{"a", "b"}},
+		{name: "subset", typ: v3.RouteType, subscribed: []string{"a", "b"}, received: []string{"a"}},
+		{name: "unrequested", typ: v3.RouteType, subscribed: []string{"a"}, received: []string{"a", "b", "c"}, want: []string{"b", "c"}},
+		{name: "nothing subscribed", typ: v3.EndpointType, received: []string{"a"}, want: []string{"a"}},
+		{name: "wildcard", typ: v3.ClusterType, received: []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _ := newTestADSC()
+			a.handleResourceUpdate(tt.typ, sets.New(tt.subscribed...))
+			got := a.checkUnrequested(tt.typ, tt.received)
+			if !sameNames(got, tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnsubscribeThenReceive(t *testing.T) {
+	a, _ := newTestADSC()
+	a.handleResourceUpdate(v3.RouteType, sets.New("a", "b"))
+	a.handleResourceUpdate(v3.RouteType, sets.New("a"))
+	// A response racing with the unsubscribe still includes b
+	if got := a.checkUnrequested(v3.RouteType, []string{"a", "b"}); !slices.Equal(got, []string{"b"}) {
+		t.Fatalf("got %v unrequested after unsubscribe, want [b]", got)
+	}
+	a.handleResourceUpdate(v3.RouteType, sets.New("a", "b"))
+	if got := a.checkUnrequested(v3.RouteType, []string{"a", "b"}); len(got) != 0 {
+		t.Fatalf("got %v unrequested after resubscribe, want none", got)
+	}
+}
+
+func TestAckTracksSubscriptions(t *testing.T) {
+	a, s := newTestADSC()
+	a.handleResourceUpdate(v3.RouteType, sets.New("a", "b"))
+	s.last()
+
+	// The response only includes a, but the ACK keeps the subscription to b
+	a.ack(&discovery.DiscoveryResponse{TypeUrl: v3.RouteType, VersionInfo: "1", Nonce: "n1"})
+	got := s.last()
+	if got.ResponseNonce != "n1" || got.VersionInfo != "1" || !slices.Equal(got.ResourceNames, []string{"a", "b"}) {
+		t.Fatalf("got ack %v", got)
+	}
+
+	// A NACK sends the last accepted version
+	a.nack(&discovery.DiscoveryResponse{TypeUrl: v3.RouteType, VersionInfo: "2", Nonce: "n2"}, &status.Status{Message: "rejected"})
+	got = s.last()
+	if got.ResponseNonce != "n2" || got.VersionInfo != "1" || got.ErrorDetail == nil || !slices.Equal(got.ResourceNames, []string{"a", "b"}) {
+		t.Fatalf("got nack %v", got)
+	}
+
+	// Subscription changes are sent with the latest nonce and accepted version
+	a.handleResourceUpdate(v3.RouteType, sets.New("a"))
+	got = s.last()
+	if got.ResponseNonce != "n2" || got.VersionInfo != "1" || !slices.Equal(got.ResourceNames, []string{"a"}) {
+		t.Fatalf("got request %v", got)
+	}
+}
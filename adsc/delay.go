@@ -0,0 +1,96 @@
+package adsc
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ProcessingDelay configures the client to model a slow consumer, such as an Envoy under CPU pressure.
+// Each response is delayed before it is processed and ACKed.
+type ProcessingDelay struct {
+	// Fixed delay added to every response.
+	Fixed time.Duration
+	// Random adds a random delay, following Distribution.
+	Random time.Duration
+	// Distribution of the Random delay: "uniform" (0 to Random, the default) or "exponential" (with mean Random).
+	Distribution string
+	// PerMB adds a delay proportional to the size of the response.
+	PerMB time.Duration
+	// MaxInFlight is the number of responses that can be received but not yet ACKed. Once reached, no more are read
+	// from the stream, so gRPC flow control pushes back on the server. Defaults to 1.
+	MaxInFlight int
+}
+
+// Enabled returns true if responses are delayed.
+func (p ProcessingDelay) Enabled() bool {
+	return p.Fixed > 0 || p.Random > 0 || p.PerMB > 0 || p.MaxInFlight > 0
+}
+
+func (p ProcessingDelay) delay(size int) time.Duration {
+	d := p.Fixed + time.Duration(float64(p.PerMB)*float64(size)/(1<<20))
+	if p.Random > 0 {
+		switch p.Distribution {
+		case "exponential":
+			d += time.Duration(rand.ExpFloat64() * float64(p.Random))
+		default:
+			d += time.Duration(rand.Int63n(int64(p.Random)))
+		}
+	}
+	return d
+}
+
+// slowReceiver wraps recv to apply the processing delay. Up to MaxInFlight responses are read ahead of the caller.
+// A response remains in flight until the following call, by which point the caller has processed and ACKed it.
+func slowReceiver[T proto.Message](ctx context.Context, cfg ProcessingDelay, recv func() (T, error)) func() (T, error) {
+	if !cfg.Enabled() {
+		return recv
+	}
+	type result struct {
+		msg T
+		err error
+	}
+	inFlight := max(cfg.MaxInFlight, 1)
+	tokens := make(chan struct{}, inFlight)
+	results := make(chan result, inFlight)
+	go func() {
+		for {
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			msg, err := recv()
+			results <- result{msg, err}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	held := false
+	return func() (T, error) {
+		if held {
+			// The previous response is done
+			<-tokens
+		}
+		held = true
+		var r result
+		select {
+		case r = <-results:
+		case <-ctx.Done():
+			return r.msg, ctx.Err()
+		}
+		if r.err != nil {
+			return r.msg, r.err
+		}
+		d := cfg.delay(proto.Size(r.msg))
+		recordProcessingDelay(d)
+		select {
+		case <-time.After(d):
+		case <-ctx.Done():
+		}
+		return r.msg, nil
+	}
+}
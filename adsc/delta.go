@@ -1,10 +1,13 @@
 package adsc
 
 import (
+	"context"
 	"fmt"
+	"maps"
 	"math"
 	"strings"
 	"sync"
+	"time"
 	"unique"
 
 	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
@@ -13,6 +16,7 @@ import (
 	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
 	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
 	v3 "istio.io/istio/pilot/pkg/xds/v3"
 	"istio.io/istio/pkg/slices"
 	"istio.io/istio/pkg/util/protomarshal"
@@ -44,17 +48,45 @@ type (
 )
 
 type deltaClient struct {
+	// ctx is scoped to this connection, and cancelled on Close, so background goroutines do not outlive it.
+	ctx            context.Context
+	cancel         context.CancelFunc
 	initialWatches []string
 	node           *core.Node
+	nodeType       string
 	conn           *grpc.ClientConn
 	client         discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesClient
+	// recv receives from the client, applying any processing delay
+	recv func() (*discovery.DeltaDiscoveryResponse, error)
 
 	// Updates includes the type of the last update received from the server.
 	updates chan string
 
+	sendMu sync.Mutex
+	// pauser coalesces subscription changes, as Envoy does
+	pauser *pauser
+	// deferredWatches are requested once the initial clusters are warm, as Envoy waits for CDS before requesting LDS
+	deferredWatches []string
+	// warming holds the endpoints and secrets required before the initial clusters are warm. Only accessed by handleRecv.
+	warming     sets.Set[ResourceKey]
+	initialized bool
+	initOnce    sync.Once
+	initTimer   *time.Timer
+
 	mu        sync.Mutex
 	resources map[IString]IStringSet
 	tree      map[ResourceKey]*ResourceNode
+
+	store     bool
+	nacker    *nacker
+	validator *validator
+	recorder  *recorder
+	// ztunnel tracks the addresses held by a ztunnel. Only set for ztunnel nodes.
+	ztunnel *ztunnelState
+	// onDemand tracks the clusters we may subscribe to. Only set if clusters are loaded on demand.
+	onDemand *onDemand
+	// decoded holds the current state of each resource, by type URL and name. Only populated if store is set.
+	decoded map[string]map[string]proto.Message
 }
 
 var _ ADSClient = &deltaClient{}
@@ -84,21 +116,52 @@ func DialDelta(url string, opts *Config) (ADSClient, error) {
 	if err != nil {
 		return nil, fmt.Errorf("stream: %v", err)
 	}
+	ctx, cancel := context.WithCancel(opts.Context)
 	c := &deltaClient{
-		initialWatches: []string{v3.ClusterType, v3.ListenerType},
-		node:           makeNode(nodeID, opts.Meta),
-		conn:           conn,
-		client:         xdsClient,
-		resources:      map[IString]IStringSet{},
+		ctx:             ctx,
+		cancel:          cancel,
+		initialWatches:  []string{v3.ClusterType},
+		deferredWatches: []string{v3.ListenerType},
+		node:            makeNode(nodeID, opts.Meta),
+		nodeType:        opts.NodeType,
+		conn:            conn,
+		client:          xdsClient,
+		resources:       map[IString]IStringSet{},
 		tree: map[ResourceKey]*ResourceNode{
 			ListenerNode.Key: ListenerNode,
 			ClusterNode.Key:  ClusterNode,
 		},
 		updates: make(chan string, 100),
+		store:   opts.StoreResponses,
+		decoded: map[string]map[string]proto.Message{},
+		nacker:  newNacker(opts.Nack),
+	}
+	c.validator = newValidator(opts.Validate, nodeID, opts.NodeType, opts.OnDemand.Enabled())
+	c.recorder, err = newRecorder(opts.RecordFile)
+	if err != nil {
+		cancel()
+		conn.Close()
+		return nil, fmt.Errorf("recorder: %v", err)
 	}
 	if opts.NodeType == "ztunnel" {
 		c.initialWatches = []string{v3.AddressType, v3.WorkloadAuthorizationType}
+		c.deferredWatches = nil
+		network, _ := opts.Meta["NETWORK"].(string)
+		c.ztunnel = newZtunnelState(opts.Ztunnel, nodeID, network+"/"+opts.IP)
+	} else {
+		c.onDemand = newOnDemand(opts.OnDemand, nodeID)
+	}
+	c.initialized = len(c.deferredWatches) == 0
+	if !c.initialized {
+		// Created before handleRecv starts, so it is never written concurrently; Watch starts it.
+		c.initTimer = time.AfterFunc(initialFetchTimeout, func() {
+			scope.Warnf("initial clusters not warmed after %v, requesting %v", initialFetchTimeout, c.deferredWatches)
+			c.finishInit()
+		})
+		c.initTimer.Stop()
 	}
+	c.pauser = newPauser(c.send)
+	c.recv = slowReceiver(opts.Context, opts.Delay, xdsClient.Recv)
 	go c.handleRecv()
 	return c, nil
 }
@@ -106,14 +169,45 @@ func DialDelta(url string, opts *Config) (ADSClient, error) {
 func (d *deltaClient) handleRecv() {
 	scope := scope.WithLabels("node", d.node.Id)
 	for {
-		msg, err := d.client.Recv()
+		msg, err := d.recv()
 		if err != nil {
 			scope.Infof("Connection closed: %v", err)
 			d.Close()
 			d.updates <- "close"
 			return
 		}
+		recordResponse(d.nodeType, msg.TypeUrl, proto.Size(msg), len(msg.Resources)+len(msg.RemovedResources))
+		d.recorder.record(msg)
+
+		if d.nacker != nil {
+			names := slices.Map(msg.Resources, (*discovery.Resource).GetName)
+			if detail := d.nacker.shouldNack(msg.TypeUrl, names); detail != nil {
+				// Rejected config is not applied, so our state is unchanged.
+				scope.Debugf("rejecting %v: %v", msg.TypeUrl, detail.Message)
+				if err := d.send(&discovery.DeltaDiscoveryRequest{
+					TypeUrl:       msg.TypeUrl,
+					ResponseNonce: msg.Nonce,
+					ErrorDetail:   detail,
+				}, ReasonNack); err != nil {
+					scope.Errorf("error sending NACK: %v", err)
+				}
+				if !d.initialized && nackCompletesInit(msg.TypeUrl) {
+					d.finishInit()
+					d.initialized = true
+				}
+				select {
+				case d.updates <- v3.GetMetricType(msg.TypeUrl):
+				default:
+				}
+				continue
+			}
+		}
 
+		d.ztunnel.observe(msg)
+		d.onDemand.observe(msg)
+		// For on-demand, clusters are only accepted once we subscribe to them
+		onDemandCDS := d.onDemand != nil && msg.TypeUrl == v3.ClusterType
+		unrequested := 0
 		requests := map[IString][]IString{}
 
 		d.mu.Lock()
@@ -129,6 +223,10 @@ func (d *deltaClient) handleRecv() {
 				Name:    name,
 				TypeUrl: typeUrl,
 			}
+			if onDemandCDS && d.tree[key] == nil {
+				unrequested++
+				continue
+			}
 			if d.tree[key] == nil && hasChildTypes(typeUrl.Value()) {
 				d.tree[key] = &ResourceNode{
 					Key:      key,
@@ -148,6 +246,9 @@ func (d *deltaClient) handleRecv() {
 			}
 			node := d.tree[key]
 			resources = resources.Insert(name)
+			if d.store {
+				d.storeResource(resp)
+			}
 			referenced := extractReferencedKeys(resp)
 			for _, rkey := range referenced {
 				child, f := d.getNode(rkey)
@@ -157,6 +258,10 @@ func (d *deltaClient) handleRecv() {
 				relate(node, child)
 			}
 		}
+		if unrequested > 0 {
+			scope.Debugf("ignoring %d unrequested clusters", unrequested)
+			recordUnrequested(d.nodeType, msg.TypeUrl, unrequested)
+		}
 		removals := map[IString][]IString{}
 		for _, resp := range msg.RemovedResources {
 			name := intern(resp)
@@ -176,9 +281,22 @@ func (d *deltaClient) handleRecv() {
 		removedLen := len(msg.RemovedResources)
 		for _, m := range msg.RemovedResources {
 			resources.Delete(intern(m))
+			delete(d.decoded[msg.TypeUrl], m)
+		}
+		// Resources we unsubscribe from will not get an explicit removal, so drop them now
+		for t, names := range removals {
+			for _, n := range names {
+				delete(d.decoded[t.Value()], n.Value())
+			}
 		}
 		d.resources[typeUrl] = resources
 		d.mu.Unlock()
+		if d.validator != nil {
+			d.validator.observe(msg.TypeUrl, slices.Map(msg.Resources, (*discovery.Resource).GetResource), msg.RemovedResources, false)
+			for t, names := range removals {
+				d.validator.forget(t.Value(), slices.Map(names, IString.Value))
+			}
+		}
 		scope.WithLabels("type", msg.TypeUrl, "added", addedLen, "removed", removedLen, "removed refs", len(removals)).Debugf("got message")
 		if dumpScope.DebugEnabled() {
 			s, _ := protomarshal.ToJSON(msg)
@@ -190,23 +308,13 @@ func (d *deltaClient) handleRecv() {
 			d.mu.Unlock()
 		}
 
-		// TODO: Envoy does some smart "pausing" to allow the next push to come before we request
+		// Like Envoy, child types are paused while the response is processed, so all resulting
+		// subscription changes go out in a single request per type, before the ACK.
+		resume := d.pauser.pause(childTypes(msg.TypeUrl)...)
 		for _, k := range keysOfMaps(requests, removals) {
-			if len(requests[k]) == 0 && len(removals[k]) == 0 {
-				continue
-			}
-			if err := d.send(&discovery.DeltaDiscoveryRequest{
-				TypeUrl: k.Value(),
-				ResourceNamesSubscribe: slices.Map(requests[k], func(e IString) string {
-					return e.Value()
-				}),
-				ResourceNamesUnsubscribe: slices.Map(removals[k], func(e IString) string {
-					return e.Value()
-				}),
-			}, ReasonRequest); err != nil {
-				scope.Errorf("error sending request: %v", err)
-			}
+			d.pauser.queue(k, requests[k], removals[k])
 		}
+		resume()
 
 		if err := d.send(&discovery.DeltaDiscoveryRequest{
 			TypeUrl:       msg.TypeUrl,
@@ -214,9 +322,70 @@ func (d *deltaClient) handleRecv() {
 		}, ReasonAck); err != nil {
 			scope.Errorf("error sending ACK: %v", err)
 		}
+
+		if !d.initialized {
+			d.trackWarming(msg, requests)
+		}
+
+		select {
+		case d.updates <- v3.GetMetricType(msg.TypeUrl):
+		default:
+		}
+	}
+}
+
+// trackWarming tracks the initial clusters until all their endpoints and secrets are received, at which point the
+// deferred watches are requested.
+func (d *deltaClient) trackWarming(msg *discovery.DeltaDiscoveryResponse, requests map[IString][]IString) {
+	switch msg.TypeUrl {
+	case v3.ClusterType:
+		if d.warming == nil {
+			d.warming = sets.New[ResourceKey]()
+			for _, t := range []IString{intern(v3.EndpointType), intern(v3.SecretType)} {
+				for _, n := range requests[t] {
+					d.warming.Insert(ResourceKey{Name: n, TypeUrl: t})
+				}
+			}
+		}
+	case v3.EndpointType, v3.SecretType:
+		for _, r := range msg.Resources {
+			d.warming.Delete(ResourceKey{Name: intern(r.Name), TypeUrl: intern(msg.TypeUrl)})
+		}
+	}
+	if d.warming != nil && len(d.warming) == 0 {
+		d.finishInit()
+		d.initialized = true
 	}
 }
 
+// finishInit requests the deferred watches. This is done at most once.
+func (d *deltaClient) finishInit() {
+	d.initOnce.Do(func() {
+		if d.initTimer != nil {
+			d.initTimer.Stop()
+		}
+		for _, res := range d.deferredWatches {
+			if err := d.send(&discovery.DeltaDiscoveryRequest{TypeUrl: res}, ReasonInit); err != nil {
+				scope.Errorf("Error sending request: %v", err)
+			}
+		}
+	})
+}
+
+func (d *deltaClient) storeResource(resp *discovery.Resource) {
+	m, err := resp.Resource.UnmarshalNew()
+	if err != nil {
+		scope.Warnf("failed to decode %v: %v", resp.Name, err)
+		return
+	}
+	typed := d.decoded[resp.Resource.TypeUrl]
+	if typed == nil {
+		typed = map[string]proto.Message{}
+		d.decoded[resp.Resource.TypeUrl] = typed
+	}
+	typed[resp.Name] = m
+}
+
 func keysOfMaps(ms ...map[IString][]IString) []IString {
 	res := []IString{}
 	for _, m := range ms {
@@ -233,6 +402,12 @@ func extractReferencedKeys(resp *discovery.Resource) []ResourceKey {
 	case v3.ClusterType:
 		o := &cluster.Cluster{}
 		_ = resp.Resource.UnmarshalTo(o)
+		// Secrets are referenced regardless of the discovery type
+		sockets := []*core.TransportSocket{o.GetTransportSocket()}
+		for _, m := range o.GetTransportSocketMatches() {
+			sockets = append(sockets, m.GetTransportSocket())
+		}
+		res = append(res, secretKeys(sockets)...)
 		// nolint
 		switch v := o.GetClusterDiscoveryType().(type) {
 		case *cluster.Cluster_Type:
@@ -248,6 +423,8 @@ func extractReferencedKeys(resp *discovery.Resource) []ResourceKey {
 	case v3.ListenerType:
 		o := &listener.Listener{}
 		_ = resp.Resource.UnmarshalTo(o)
+		extensions := sets.New[string]()
+		sockets := []*core.TransportSocket{}
 		for _, fc := range getFilterChains(o) {
 			for _, f := range fc.GetFilters() {
 				if f.GetTypedConfig().GetTypeUrl() == "type.googleapis.com/envoy.extensions.filters.network.http_connection_manager.v3.HttpConnectionManager" {
@@ -260,9 +437,45 @@ func extractReferencedKeys(resp *discovery.Resource) []ResourceKey {
 						}
 						res = append(res, key)
 					}
+					for _, hf := range hcm.GetHttpFilters() {
+						if hf.GetConfigDiscovery() != nil {
+							extensions.Insert(hf.GetName())
+						}
+					}
+				}
+				if f.GetConfigDiscovery() != nil {
+					extensions.Insert(f.GetName())
 				}
 			}
+			sockets = append(sockets, fc.GetTransportSocket())
 		}
+		for _, e := range sets.SortedList(extensions) {
+			res = append(res, ResourceKey{
+				Name:    intern(e),
+				TypeUrl: intern(v3.ExtensionConfigurationType),
+			})
+		}
+		res = append(res, secretKeys(sockets)...)
+	}
+	return res
+}
+
+// secretKeys returns the SDS secrets referenced by the transport sockets. Like the SotW client, secrets served
+// by the local agent rather than Istiod are skipped.
+func secretKeys(sockets []*core.TransportSocket) []ResourceKey {
+	secrets := sets.New[string]()
+	for _, s := range sockets {
+		secrets.InsertAll(transportSocketSecrets(s)...)
+	}
+	secrets.Delete("")
+	secrets.Delete("ROOTCA")
+	secrets.Delete("default")
+	res := make([]ResourceKey, 0, len(secrets))
+	for _, s := range sets.SortedList(secrets) {
+		res = append(res, ResourceKey{
+			Name:    intern(s),
+			TypeUrl: intern(v3.SecretType),
+		})
 	}
 	return res
 }
@@ -274,11 +487,22 @@ func relate(parent, child *ResourceNode) {
 
 func (d *deltaClient) Watch() {
 	scope.Infof("sending initial watches")
+	if d.initTimer != nil {
+		d.initTimer.Reset(initialFetchTimeout)
+	}
 	first := true
 	for _, res := range d.initialWatches {
 		req := &discovery.DeltaDiscoveryRequest{
 			TypeUrl: res,
 		}
+		if d.ztunnel != nil {
+			req.ResourceNamesSubscribe = d.ztunnel.initialSubscriptions(res)
+		}
+		if d.onDemand != nil && res == v3.ClusterType {
+			// Subscribing and unsubscribing to "*" subscribes to nothing, rather than everything
+			req.ResourceNamesSubscribe = []string{"*"}
+			req.ResourceNamesUnsubscribe = []string{"*"}
+		}
 		if first {
 			req.Node = d.node
 			first = false
@@ -288,16 +512,51 @@ func (d *deltaClient) Watch() {
 			scope.Errorf("Error sending request: %v", err)
 		}
 	}
+	if d.ztunnel != nil {
+		go d.ztunnel.runLookups(d.ctx, d.pauser)
+	}
+	if d.onDemand != nil {
+		go d.onDemand.run(d.ctx, d.call)
+	}
+}
+
+// call subscribes to the cluster (and, with VHDS, virtual host) for a service, as Envoy does on the first request.
+func (d *deltaClient) call(cluster, vhost string) {
+	key := ResourceKey{Name: intern(cluster), TypeUrl: intern(v3.ClusterType)}
+	d.mu.Lock()
+	node, _ := d.getNode(key)
+	relate(d.tree[ResourceKey{TypeUrl: intern(v3.ClusterType)}], node)
+	d.mu.Unlock()
+	d.pauser.queue(key.TypeUrl, []IString{key.Name}, nil)
+	if d.onDemand.cfg.VirtualHosts {
+		d.pauser.queue(intern(virtualHostType), []IString{intern(vhost)}, nil)
+	}
 }
 
 func (d *deltaClient) Close() {
+	d.cancel()
+	d.ztunnel.close()
+	d.validator.stop()
+	d.recorder.close()
+	if d.initTimer != nil {
+		d.initTimer.Stop()
+	}
 	if d.conn != nil {
 		d.conn.Close()
 	}
 }
 
 func (d *deltaClient) Responses() Responses {
-	panic("implement me")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return Responses{
+		Clusters:   maps.Clone(d.decoded[v3.ClusterType]),
+		Listeners:  maps.Clone(d.decoded[v3.ListenerType]),
+		Routes:     maps.Clone(d.decoded[v3.RouteType]),
+		Endpoints:  maps.Clone(d.decoded[v3.EndpointType]),
+		Extensions: maps.Clone(d.decoded[v3.ExtensionConfigurationType]),
+		Secrets:    maps.Clone(d.decoded[v3.SecretType]),
+	}
 }
 
 func (d *deltaClient) Updates() chan string {
@@ -306,6 +565,9 @@ func (d *deltaClient) Updates() chan string {
 
 func (d *deltaClient) send(dr *discovery.DeltaDiscoveryRequest, reason string) error {
 	scope.Debugf("send message for type %v (%v) for +%v -%v", dr.TypeUrl, reason, dr.ResourceNamesSubscribe, dr.ResourceNamesUnsubscribe)
+	recordRequest(d.nodeType, dr.TypeUrl, reason)
+	d.sendMu.Lock()
+	defer d.sendMu.Unlock()
 	return d.client.Send(dr)
 }
 
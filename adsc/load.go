@@ -5,6 +5,9 @@ import (
 	"time"
 
 	"github.com/cenkalti/backoff"
+	"istio.io/istio/pkg/util/sets"
+
+	"github.com/howardjohn/pilot-load/pkg/stats"
 )
 
 func Fetch(pilotAddress string, config *Config) (*Responses, error) {
@@ -53,12 +56,14 @@ func Connect(pilotAddress string, config *Config) {
 	b.MaxElapsedTime = 0
 	b.MaxInterval = time.Second * 30
 	b.InitialInterval = time.Millisecond * 500
+	reconnecting := false
 	for {
 		t0 := time.Now()
 		log("Connecting: %v", config.IP)
 		con, err := Dial(pilotAddress, config)
 		if err != nil {
 			log("Error in ADS connection: %v", err)
+			stats.Errors.Inc("xds-connect")
 			attempts++
 			bo := b.NextBackOff()
 			select {
@@ -75,9 +80,16 @@ func Connect(pilotAddress string, config *Config) {
 		}
 
 		log("Connected: %v in %v", config.IP, time.Since(t0))
+		recordConnect(config.NodeType, time.Since(t0))
+		stats.XDSConnect.Record(time.Since(t0))
+		if reconnecting {
+			recordReconnect(config.NodeType)
+			reconnecting = false
+		}
 		con.Watch()
 
 		update := false
+		seen := sets.New[string]()
 		exit := false
 		for !exit {
 			select {
@@ -91,6 +103,10 @@ func Connect(pilotAddress string, config *Config) {
 					update = true
 					b.Reset()
 					log("Got Initial Update: %v for %v in %v", config.IP, u, time.Since(t0))
+					stats.XDSFirstPush.Record(time.Since(t0))
+				}
+				if u != "close" && !seen.InsertContains(u) {
+					recordFirstResponse(config.NodeType, u, time.Since(t0))
 				}
 				if config.Updates != nil {
 					config.Updates <- u
@@ -102,6 +118,7 @@ func Connect(pilotAddress string, config *Config) {
 				return
 			}
 		}
+		reconnecting = true
 		bo := b.NextBackOff()
 		log("Disconnected: %v, retrying in %v", config.IP, bo)
 		select {
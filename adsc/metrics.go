@@ -0,0 +1,135 @@
+package adsc
+
+import (
+	"sync"
+	"time"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/monitoring"
+)
+
+var (
+	nodeTypeTag = monitoring.CreateLabel("node_type")
+	typeTag     = monitoring.CreateLabel("type")
+	reasonTag   = monitoring.CreateLabel("reason")
+
+	connectTime = monitoring.NewDistribution(
+		"pilot_load_xds_connect_time",
+		"Time from starting a connection until the stream is established.",
+		[]float64{.01, .1, .5, 1, 3, 5, 10, 20, 30, 60, 120},
+		monitoring.WithUnit(monitoring.Seconds),
+	)
+
+	firstResponseTime = monitoring.NewDistribution(
+		"pilot_load_xds_first_response_time",
+		"Time from starting a connection until the first response of each type is received.",
+		[]float64{.01, .1, .5, 1, 3, 5, 10, 20, 30, 60, 120},
+		monitoring.WithUnit(monitoring.Seconds),
+	)
+
+	responses = monitoring.NewSum(
+		"pilot_load_xds_responses",
+		"Total number of responses received, by type.",
+	)
+
+	responseBytes = monitoring.NewSum(
+		"pilot_load_xds_response_bytes",
+		"Total size of responses received, by type.",
+		monitoring.WithUnit(monitoring.Bytes),
+	)
+
+	responseResources = monitoring.NewDistribution(
+		"pilot_load_xds_response_resources",
+		"Number of resources (including removals) in each response.",
+		[]float64{1, 10, 100, 1000, 10000, 100000},
+	)
+
+	requests = monitoring.NewSum(
+		"pilot_load_xds_requests",
+		"Total number of requests sent, by type and reason (init, request, ack, nack).",
+	)
+
+	processingDelay = monitoring.NewDistribution(
+		"pilot_load_xds_processing_delay",
+		"Simulated processing time applied to each response before it is handled.",
+		[]float64{.001, .01, .1, .5, 1, 5, 10, 30},
+		monitoring.WithUnit(monitoring.Seconds),
+	)
+
+	unrequested = monitoring.NewSum(
+		"pilot_load_xds_unrequested_resources",
+		"Total number of resources received that were not subscribed to, by type.",
+	)
+
+	checkTag = monitoring.CreateLabel("check")
+
+	validationErrors = monitoring.NewSum(
+		"pilot_load_xds_validation_errors",
+		"Total number of invalid resources or broken references found, by type and check.",
+	)
+
+	kindTag = monitoring.CreateLabel("kind")
+
+	ztunnelResources = monitoring.NewGauge(
+		"pilot_load_ztunnel_resources",
+		"Number of workloads, services, and authorizations currently held, summed over all ztunnels.",
+	)
+
+	reconnects = monitoring.NewSum(
+		"pilot_load_xds_reconnects",
+		"Total number of times a connection was re-established after being closed or failing.",
+	)
+)
+
+func recordResponse(nodeType, typeURL string, size int, resources int) {
+	t := typeTag.Value(v3.GetMetricType(typeURL))
+	n := nodeTypeTag.Value(nodeType)
+	responses.With(n, t).Increment()
+	responseBytes.With(n, t).RecordInt(int64(size))
+	responseResources.With(n, t).RecordInt(int64(resources))
+}
+
+func recordRequest(nodeType, typeURL string, reason string) {
+	requests.With(nodeTypeTag.Value(nodeType), typeTag.Value(v3.GetMetricType(typeURL)), reasonTag.Value(reason)).Increment()
+}
+
+func recordConnect(nodeType string, d time.Duration) {
+	connectTime.With(nodeTypeTag.Value(nodeType)).Record(d.Seconds())
+}
+
+func recordFirstResponse(nodeType, update string, d time.Duration) {
+	firstResponseTime.With(nodeTypeTag.Value(nodeType), typeTag.Value(update)).Record(d.Seconds())
+}
+
+func recordProcessingDelay(d time.Duration) {
+	processingDelay.Record(d.Seconds())
+}
+
+func recordUnrequested(nodeType, typeURL string, count int) {
+	unrequested.With(nodeTypeTag.Value(nodeType), typeTag.Value(v3.GetMetricType(typeURL))).RecordInt(int64(count))
+}
+
+func recordValidationError(nodeType, typeURL, check string) {
+	validationErrors.With(nodeTypeTag.Value(nodeType), typeTag.Value(v3.GetMetricType(typeURL)), checkTag.Value(check)).Increment()
+}
+
+func recordReconnect(nodeType string) {
+	reconnects.With(nodeTypeTag.Value(nodeType)).Increment()
+}
+
+var (
+	ztunnelTotalsMu sync.Mutex
+	ztunnelTotals   = map[string]int{}
+)
+
+// recordZtunnelResources adds delta to the number of resources of the kind held by all ztunnels. A per ztunnel
+// series would be unbounded, at the scale we simulate.
+func recordZtunnelResources(kind string, delta int) {
+	if delta == 0 {
+		return
+	}
+	ztunnelTotalsMu.Lock()
+	defer ztunnelTotalsMu.Unlock()
+	ztunnelTotals[kind] += delta
+	ztunnelResources.With(kindTag.Value(kind)).RecordInt(int64(ztunnelTotals[kind]))
+}
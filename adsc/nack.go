@@ -0,0 +1,112 @@
+package adsc
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+// NackConfig configures the client to reject (NACK) some responses, rather than ACKing everything.
+// All of the configured conditions must match for a response to be rejected.
+type NackConfig struct {
+	// Percent of responses to NACK, from 0 to 100. If unset, all matching responses are NACKed.
+	Percent float64
+	// TypeUrls restricts NACKs to these types. Both full type URLs and short names (cds, lds, ...) are accepted.
+	TypeUrls []string
+	// Resources restricts NACKs to responses containing a resource with a name matching this expression.
+	Resources *regexp.Regexp
+	// UntilVersion, if set, NACKs responses of each type until the Nth version, which is ACKed along with all later versions.
+	UntilVersion int
+	// Detail is the message sent in the NACK error detail.
+	Detail string
+}
+
+// Enabled returns true if any NACKs may be sent.
+func (n NackConfig) Enabled() bool {
+	return n.Percent > 0 || len(n.TypeUrls) > 0 || n.Resources != nil || n.UntilVersion > 0
+}
+
+// Validate checks the config is well formed.
+func (n NackConfig) Validate() error {
+	if n.Percent < 0 || n.Percent > 100 {
+		return fmt.Errorf("nack percent must be between 0 and 100, got %v", n.Percent)
+	}
+	if n.UntilVersion < 0 {
+		return fmt.Errorf("nack until version must not be negative, got %v", n.UntilVersion)
+	}
+	return nil
+}
+
+// nacker decides which responses on a single connection to NACK.
+type nacker struct {
+	config NackConfig
+	// versions counts the responses received, by type
+	versions map[string]int
+}
+
+// newNacker returns a nacker for the config, or nil if NACKs are disabled.
+func newNacker(cfg NackConfig) *nacker {
+	if !cfg.Enabled() {
+		return nil
+	}
+	return &nacker{config: cfg, versions: map[string]int{}}
+}
+
+// shouldNack returns the error detail to send if the response should be NACKed, or nil if it should be ACKed.
+// names are the names of the resources in the response.
+func (n *nacker) shouldNack(typeURL string, names []string) *status.Status {
+	if n == nil {
+		return nil
+	}
+	n.versions[typeURL]++
+	cfg := n.config
+	if len(cfg.TypeUrls) > 0 && !n.matchesType(typeURL) {
+		return nil
+	}
+	if cfg.Resources != nil && !n.matchesResource(names) {
+		return nil
+	}
+	if cfg.UntilVersion > 0 && n.versions[typeURL] >= cfg.UntilVersion {
+		return nil
+	}
+	if cfg.Percent > 0 && rand.Float64()*100 >= cfg.Percent {
+		return nil
+	}
+	detail := cfg.Detail
+	if detail == "" {
+		detail = "pilot-load: injected NACK"
+	}
+	return &status.Status{
+		Code:    int32(codes.InvalidArgument),
+		Message: detail,
+	}
+}
+
+func (n *nacker) matchesType(typeURL string) bool {
+	short := v3.GetMetricType(typeURL)
+	for _, t := range n.config.TypeUrls {
+		if t == typeURL || t == short {
+			return true
+		}
+	}
+	return false
+}
+
+func (n *nacker) matchesResource(names []string) bool {
+	for _, name := range names {
+		if n.config.Resources.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// nackCompletesInit returns true if rejecting the type completes initialization. Like Envoy, a rejected CDS or LDS
+// response ends warming, while rejected endpoints or secrets leave the clusters warming.
+func nackCompletesInit(typeURL string) bool {
+	return typeURL == v3.ClusterType || typeURL == v3.ListenerType
+}
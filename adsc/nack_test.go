@@ -0,0 +1,127 @@
+package adsc
+
+import (
+	"regexp"
+	"testing"
+
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func TestNackConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     NackConfig
+		wantErr bool
+	}{
+		{name: "empty", cfg: NackConfig{}},
+		{name: "zero percent", cfg: NackConfig{Percent: 0}},
+		{name: "full percent", cfg: NackConfig{Percent: 100}},
+		{name: "fractional percent", cfg: NackConfig{Percent: 0.5}},
+		{name: "over 100", cfg: NackConfig{Percent: 100.1}, wantErr: true},
+		{name: "negative", cfg: NackConfig{Percent: -1}, wantErr: true},
+		{name: "negative until", cfg: NackConfig{UntilVersion: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestShouldNack(t *testing.T) {
+	if newNacker(NackConfig{}) != nil {
+		t.Fatal("expected disabled nacker")
+	}
+	tests := []struct {
+		name  string
+		cfg   NackConfig
+		typ   string
+		names []string
+		// want is whether each of three consecutive responses is NACKed
+		want []bool
+	}{
+		{
+			name: "all",
+			cfg:  NackConfig{Percent: 100},
+			typ:  v3.ClusterType,
+			want: []bool{true, true, true},
+		},
+		{
+			name: "short type matches",
+			cfg:  NackConfig{TypeUrls: []string{"cds"}},
+			typ:  v3.ClusterType,
+			want: []bool{true, true, true},
+		},
+		{
+			name: "other type",
+			cfg:  NackConfig{TypeUrls: []string{"lds"}},
+			typ:  v3.ClusterType,
+			want: []bool{false, false, false},
+		},
+		{
+			name:  "resource matches",
+			cfg:   NackConfig{Resources: regexp.MustCompile("^outbound")},
+			typ:   v3.ClusterType,
+			names: []string{"inbound", "outbound|80||a"},
+			want:  []bool{true, true, true},
+		},
+		{
+			name:  "resource does not match",
+			cfg:   NackConfig{Resources: regexp.MustCompile("^outbound")},
+			typ:   v3.ClusterType,
+			names: []string{"inbound"},
+			want:  []bool{false, false, false},
+		},
+		{
+			name: "until version",
+			cfg:  NackConfig{UntilVersion: 3},
+			typ:  v3.ClusterType,
+			want: []bool{true, true, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := newNacker(tt.cfg)
+			for i, want := range tt.want {
+				if got := n.shouldNack(tt.typ, tt.names) != nil; got != want {
+					t.Fatalf("response %d: got nack %v, want %v", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestShouldNackPercent(t *testing.T) {
+	n := newNacker(NackConfig{Percent: 25})
+	nacks := 0
+	for range 10000 {
+		if n.shouldNack(v3.ClusterType, nil) != nil {
+			nacks++
+		}
+	}
+	if nacks < 2000 || nacks > 3000 {
+		t.Fatalf("expected about 25%% of responses NACKed, got %d of 10000", nacks)
+	}
+}
+
+func TestNackCompletesInit(t *testing.T) {
+	tests := []struct {
+		typeURL string
+		want    bool
+	}{
+		{v3.ClusterType, true},
+		{v3.ListenerType, true},
+		{v3.EndpointType, false},
+		{v3.SecretType, false},
+		{v3.RouteType, false},
+	}
+	for _, tt := range tests {
+		t.Run(v3.GetShortType(tt.typeURL), func(t *testing.T) {
+			if got := nackCompletesInit(tt.typeURL); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
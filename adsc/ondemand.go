@@ -0,0 +1,154 @@
+package adsc
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// virtualHostType is the VHDS type. Resources are named "<route config>/<host>".
+const virtualHostType = "type.googleapis.com/envoy.config.route.v3.VirtualHost"
+
+// OnDemandConfig configures the client to load clusters on demand (ODCDS), as Envoy does with on-demand cluster
+// discovery, rather than subscribing to all clusters. Only supported with delta XDS.
+type OnDemandConfig struct {
+	// Rate is the number of new services called per minute. Each call subscribes to a random cluster referenced by
+	// the routes received, modeling the first request to that service. If zero, all clusters are subscribed to.
+	Rate float64
+	// VirtualHosts additionally subscribes to the virtual host for each call, with VHDS.
+	VirtualHosts bool
+}
+
+// Enabled returns true if clusters are loaded on demand.
+func (o OnDemandConfig) Enabled() bool {
+	return o.Rate > 0
+}
+
+// Validate checks the config is well formed.
+func (o OnDemandConfig) Validate() error {
+	if o.Rate < 0 || math.IsNaN(o.Rate) || math.IsInf(o.Rate, 0) {
+		return fmt.Errorf("on-demand rate must be a positive number, got %v", o.Rate)
+	}
+	if o.VirtualHosts && !o.Enabled() {
+		return fmt.Errorf("on-demand virtual hosts require an on-demand rate")
+	}
+	return nil
+}
+
+// interval returns the time between calls. Very high rates are capped, rather than rounding to zero.
+func (o OnDemandConfig) interval() time.Duration {
+	return max(time.Duration(float64(time.Minute)/o.Rate), time.Nanosecond)
+}
+
+// onDemand tracks the services that can be called, from the routes received, and which have been called.
+type onDemand struct {
+	cfg OnDemandConfig
+	log *log.Scope
+
+	mu sync.Mutex
+	// routes holds, for each route configuration, the virtual host name for each cluster it references
+	routes map[string]map[string]string
+	called sets.String
+}
+
+func newOnDemand(cfg OnDemandConfig, nodeID string) *onDemand {
+	if !cfg.Enabled() {
+		return nil
+	}
+	return &onDemand{
+		cfg:    cfg,
+		log:    scope.WithLabels("node", nodeID),
+		routes: map[string]map[string]string{},
+		called: sets.New[string](),
+	}
+}
+
+// observe records the clusters referenced by received routes.
+func (o *onDemand) observe(msg *discovery.DeltaDiscoveryResponse) {
+	if o == nil || msg.TypeUrl != v3.RouteType {
+		return
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, r := range msg.RemovedResources {
+		delete(o.routes, r)
+	}
+	for _, r := range msg.Resources {
+		rc := &route.RouteConfiguration{}
+		if err := r.GetResource().UnmarshalTo(rc); err != nil {
+			o.log.Warnf("failed to decode route %v: %v", r.Name, err)
+			continue
+		}
+		clusters := map[string]string{}
+		for _, vh := range rc.GetVirtualHosts() {
+			if len(vh.GetDomains()) == 0 {
+				continue
+			}
+			vhost := rc.GetName() + "/" + vh.GetDomains()[0]
+			for _, rt := range vh.GetRoutes() {
+				if c := rt.GetRoute().GetCluster(); c != "" {
+					clusters[c] = vhost
+				}
+				for _, wc := range rt.GetRoute().GetWeightedClusters().GetClusters() {
+					clusters[wc.GetName()] = vhost
+				}
+			}
+		}
+		o.routes[r.Name] = clusters
+	}
+}
+
+// next picks a service that has not yet been called, returning its cluster and virtual host.
+func (o *onDemand) next() (string, string, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	candidates := map[string]string{}
+	for _, clusters := range o.routes {
+		for c, vh := range clusters {
+			if !o.called.Contains(c) {
+				candidates[c] = vh
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		return "", "", false
+	}
+	keys := maps.Keys(candidates)
+	c := keys[rand.Intn(len(keys))]
+	o.called.Insert(c)
+	return c, candidates[c], true
+}
+
+// run calls a new service at the configured rate, until the context ends.
+func (o *onDemand) run(ctx context.Context, call func(cluster, vhost string)) {
+	t := time.NewTicker(o.cfg.interval())
+	defer t.Stop()
+	for waitTick(ctx, t) {
+		c, vh, ok := o.next()
+		if !ok {
+			continue
+		}
+		o.log.Debugf("calling %v", c)
+		call(c, vh)
+	}
+}
+
+// waitTick waits for the next tick, returning false once the context is done. If both are ready, select picks either,
+// so the context is checked again after a tick.
+func waitTick(ctx context.Context, t *time.Ticker) bool {
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+	return ctx.Err() == nil
+}
@@ -0,0 +1,48 @@
+package adsc
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestOnDemandConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     OnDemandConfig
+		wantErr bool
+	}{
+		{name: "disabled", cfg: OnDemandConfig{}},
+		{name: "enabled", cfg: OnDemandConfig{Rate: 10}},
+		{name: "virtual hosts", cfg: OnDemandConfig{Rate: 10, VirtualHosts: true}},
+		{name: "very high rate", cfg: OnDemandConfig{Rate: math.MaxFloat64}},
+		{name: "negative", cfg: OnDemandConfig{Rate: -1}, wantErr: true},
+		{name: "infinite", cfg: OnDemandConfig{Rate: math.Inf(1)}, wantErr: true},
+		{name: "NaN", cfg: OnDemandConfig{Rate: math.NaN()}, wantErr: true},
+		{name: "virtual hosts without rate", cfg: OnDemandConfig{VirtualHosts: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOnDemandInterval(t *testing.T) {
+	tests := []struct {
+		rate float64
+		want time.Duration
+	}{
+		{rate: 1, want: time.Minute},
+		{rate: 120, want: 500 * time.Millisecond},
+		// Rounds to zero, which would panic in a ticker
+		{rate: math.MaxFloat64, want: time.Nanosecond},
+	}
+	for _, tt := range tests {
+		if got := (OnDemandConfig{Rate: tt.rate}).interval(); got != tt.want {
+			t.Fatalf("rate %v: got %v, want %v", tt.rate, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package adsc
+
+import (
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/slices"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// initialFetchTimeout matches Envoy's default initial_fetch_timeout. If clusters are not warmed by then,
+// listeners are requested anyway.
+const initialFetchTimeout = 15 * time.Second
+
+// pendingRequest holds subscription changes for a type, to be sent as a single request.
+type pendingRequest struct {
+	subscribe   IStringSet
+	unsubscribe IStringSet
+}
+
+// pauser models Envoy's xDS pause/resume semantics. While a type is paused, subscription changes for it are
+// coalesced, and sent in a single request once all pauses are released.
+type pauser struct {
+	mu      sync.Mutex
+	paused  map[IString]int
+	pending map[IString]*pendingRequest
+	send    func(dr *discovery.DeltaDiscoveryRequest, reason string) error
+}
+
+func newPauser(send func(dr *discovery.DeltaDiscoveryRequest, reason string) error) *pauser {
+	return &pauser{
+		paused:  map[IString]int{},
+		pending: map[IString]*pendingRequest{},
+		send:    send,
+	}
+}
+
+// pause holds requests for the types until the returned function is called.
+func (p *pauser) pause(types ...IString) func() {
+	p.mu.Lock()
+	for _, t := range types {
+		p.paused[t]++
+	}
+	p.mu.Unlock()
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		for _, t := range types {
+			p.paused[t]--
+			if p.paused[t] == 0 {
+				delete(p.paused, t)
+				p.flush(t)
+			}
+		}
+	}
+}
+
+// queue adds subscription changes for the type, sending them immediately if the type is not paused.
+func (p *pauser) queue(typeURL IString, subscribe, unsubscribe []IString) {
+	if len(subscribe) == 0 && len(unsubscribe) == 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pr := p.pending[typeURL]
+	if pr == nil {
+		pr = &pendingRequest{subscribe: sets.New[IString](), unsubscribe: sets.New[IString]()}
+		p.pending[typeURL] = pr
+	}
+	// A later change for the same resource cancels out an earlier one
+	for _, n := range subscribe {
+		pr.unsubscribe.Delete(n)
+		pr.subscribe.Insert(n)
+	}
+	for _, n := range unsubscribe {
+		pr.subscribe.Delete(n)
+		pr.unsubscribe.Insert(n)
+	}
+	if p.paused[typeURL] == 0 {
+		p.flush(typeURL)
+	}
+}
+
+// flush sends any pending request for the type. Must be called with the lock held.
+func (p *pauser) flush(typeURL IString) {
+	pr := p.pending[typeURL]
+	delete(p.pending, typeURL)
+	if pr == nil || (len(pr.subscribe) == 0 && len(pr.unsubscribe) == 0) {
+		return
+	}
+	if err := p.send(&discovery.DeltaDiscoveryRequest{
+		TypeUrl:                  typeURL.Value(),
+		ResourceNamesSubscribe:   slices.Map(pr.subscribe.UnsortedList(), IString.Value),
+		ResourceNamesUnsubscribe: slices.Map(pr.unsubscribe.UnsortedList(), IString.Value),
+	}, ReasonRequest); err != nil {
+		scope.Errorf("error sending request: %v", err)
+	}
+}
+
+// childTypes returns the types that are paused while processing a response of the given type.
+// This follows Envoy, which pauses EDS and SDS during CDS updates, and RDS and SDS during LDS updates.
+func childTypes(typeURL string) []IString {
+	switch typeURL {
+	case v3.ClusterType:
+		return []IString{intern(v3.EndpointType), intern(v3.SecretType)}
+	case v3.ListenerType:
+		return []IString{intern(v3.RouteType), intern(v3.SecretType)}
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package adsc
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// A recording is a gzip stream of records, each made up of the receive time (as a uvarint of Unix nanoseconds)
+// followed by the response, wrapped in an Any and length delimited. Each connection appends a new gzip member,
+// so reconnects are captured in the same file.
+
+// Record is a single response captured in a recording.
+type Record struct {
+	Time time.Time
+	// Exactly one of SotW or Delta is set.
+	SotW  *discovery.DiscoveryResponse
+	Delta *discovery.DeltaDiscoveryResponse
+}
+
+// recorder writes each response received on a connection to a file.
+type recorder struct {
+	mu     sync.Mutex
+	file   *os.File
+	gz     *gzip.Writer
+	buf    []byte
+	closed bool
+}
+
+// RecordingFile returns the file that a recording for the workload is written to, in the directory.
+// If dir is empty, recording is disabled and an empty path is returned.
+func RecordingFile(dir string, workload string) string {
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, strings.ReplaceAll(workload, "/", "_")+".xds.gz")
+}
+
+// newRecorder returns a recorder writing to the file, or nil if path is empty.
+func newRecorder(path string) (*recorder, error) {
+	if path == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &recorder{file: f, gz: gzip.NewWriter(f)}, nil
+}
+
+func (r *recorder) record(msg proto.Message) {
+	if r == nil {
+		return
+	}
+	a, err := anypb.New(msg)
+	if err != nil {
+		scope.Warnf("failed to record response: %v", err)
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	r.buf = binary.AppendUvarint(r.buf[:0], uint64(time.Now().UnixNano()))
+	if _, err := r.gz.Write(r.buf); err != nil {
+		scope.Warnf("failed to record response: %v", err)
+		return
+	}
+	if _, err := protodelim.MarshalTo(r.gz, a); err != nil {
+		scope.Warnf("failed to record response: %v", err)
+		return
+	}
+	// Flush so the recording is usable even if we do not exit cleanly
+	_ = r.gz.Flush()
+}
+
+func (r *recorder) close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+	r.closed = true
+	_ = r.gz.Close()
+	_ = r.file.Close()
+}
+
+// ReadRecording reads all records from a recording file.
+func ReadRecording(path string) ([]Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(gz)
+	res := []Record{}
+	for {
+		ts, err := binary.ReadUvarint(br)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			// An unexpected EOF is a truncated final record, likely from an unclean exit
+			return res, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read timestamp: %v", err)
+		}
+		a := &anypb.Any{}
+		if err := protodelim.UnmarshalFrom(br, a); err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+				return res, nil
+			}
+			return nil, fmt.Errorf("read response: %v", err)
+		}
+		m, err := a.UnmarshalNew()
+		if err != nil {
+			return nil, fmt.Errorf("decode response: %v", err)
+		}
+		rec := Record{Time: time.Unix(0, int64(ts))}
+		switch m := m.(type) {
+		case *discovery.DiscoveryResponse:
+			rec.SotW = m
+		case *discovery.DeltaDiscoveryResponse:
+			rec.Delta = m
+		default:
+			return nil, fmt.Errorf("unexpected record type %T", m)
+		}
+		res = append(res, rec)
+	}
+}
@@ -0,0 +1,82 @@
+package adsc
+
+import (
+	"path/filepath"
+	"testing"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"google.golang.org/protobuf/proto"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func TestRecordingRoundTrip(t *testing.T) {
+	path := RecordingFile(t.TempDir(), "ns/app")
+	if filepath.Base(path) != "ns_app.xds.gz" {
+		t.Fatalf("unexpected recording file %v", path)
+	}
+	sotw := &discovery.DiscoveryResponse{TypeUrl: v3.ClusterType, VersionInfo: "1", Nonce: "a"}
+	delta := &discovery.DeltaDiscoveryResponse{TypeUrl: v3.ListenerType, SystemVersionInfo: "2", Nonce: "b"}
+
+	// Each connection appends its own gzip member, as on a reconnect
+	for _, msg := range []proto.Message{sotw, delta} {
+		r, err := newRecorder(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.record(msg)
+		r.close()
+		// Records after close are dropped
+		r.record(msg)
+	}
+
+	records, err := ReadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if !proto.Equal(records[0].SotW, sotw) || records[0].Delta != nil {
+		t.Fatalf("got first record %+v, want %v", records[0], sotw)
+	}
+	if !proto.Equal(records[1].Delta, delta) || records[1].SotW != nil {
+		t.Fatalf("got second record %+v, want %v", records[1], delta)
+	}
+	if records[1].Time.Before(records[0].Time) {
+		t.Fatalf("record times out of order: %v, %v", records[0].Time, records[1].Time)
+	}
+}
+
+func TestReadRecordingTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.xds.gz")
+	r, err := newRecorder(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"1", "2"} {
+		r.record(&discovery.DiscoveryResponse{TypeUrl: v3.ClusterType, VersionInfo: v})
+	}
+	// Simulate an unclean exit: the writes are flushed, but the gzip stream is never closed
+	_ = r.file.Close()
+
+	records, err := ReadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].SotW.GetVersionInfo() != "2" {
+		t.Fatalf("got %+v, want both records", records)
+	}
+}
+
+func TestRecorderDisabled(t *testing.T) {
+	r, err := newRecorder("")
+	if err != nil || r != nil {
+		t.Fatalf("got %v, %v, want nil recorder", r, err)
+	}
+	// A nil recorder is a no-op
+	r.record(&discovery.DiscoveryResponse{})
+	r.close()
+	if f := RecordingFile("", "app"); f != "" {
+		t.Fatalf("got recording file %q, want none", f)
+	}
+}
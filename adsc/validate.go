@@ -0,0 +1,253 @@
+package adsc
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	cluster "github.com/envoyproxy/go-control-plane/envoy/config/cluster/v3"
+	endpoint "github.com/envoyproxy/go-control-plane/envoy/config/endpoint/v3"
+	listener "github.com/envoyproxy/go-control-plane/envoy/config/listener/v3"
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	hcm "github.com/envoyproxy/go-control-plane/envoy/extensions/filters/network/http_connection_manager/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/util/sets"
+)
+
+// validationDelay is how long to wait after the last response before checking cross references. As related
+// resources arrive in separate responses, checking immediately would report transient inconsistencies.
+const validationDelay = 2 * time.Second
+
+const (
+	checkProto           = "proto"
+	checkRouteCluster    = "route-cluster"
+	checkListenerRoute   = "listener-route"
+	checkClusterEndpoint = "cluster-endpoints"
+)
+
+// validator checks the config received on a single connection. Each resource is checked with Envoy's proto
+// validation as it is received, and references between resources are checked once responses settle.
+type validator struct {
+	nodeType string
+	log      *log.Scope
+	// onDemand is set when clusters are loaded on demand, so routes are expected to reference unknown clusters
+	onDemand bool
+
+	mu sync.Mutex
+	// edsClusters records, for each cluster, whether it uses EDS
+	edsClusters map[string]bool
+	endpoints   sets.String
+	// listenerRoutes holds the RDS names referenced by each listener
+	listenerRoutes map[string][]string
+	// routeClusters holds the clusters referenced by each route configuration
+	routeClusters map[string][]string
+	// violations holds the reference violations found by the last check, so persistent problems are not repeatedly reported
+	violations sets.String
+	timer      *time.Timer
+	// stopped is set once the connection is closed, after which no checks are run
+	stopped bool
+}
+
+// newValidator returns a validator for the node, or nil if validation is disabled.
+func newValidator(enabled bool, nodeID, nodeType string, onDemand bool) *validator {
+	if !enabled {
+		return nil
+	}
+	return &validator{
+		nodeType:       nodeType,
+		log:            scope.WithLabels("node", nodeID),
+		onDemand:       onDemand,
+		edsClusters:    map[string]bool{},
+		endpoints:      sets.New[string](),
+		listenerRoutes: map[string][]string{},
+		routeClusters:  map[string][]string{},
+		violations:     sets.New[string](),
+	}
+}
+
+// observe validates resources received for the type. If replace is set (as with SotW), the resources are the full
+// state for the type; otherwise they are incremental, with removed holding the names of any removed resources.
+func (v *validator) observe(typeURL string, resources []*anypb.Any, removed []string, replace bool) {
+	if v == nil {
+		return
+	}
+	switch typeURL {
+	case v3.ClusterType, v3.EndpointType, v3.ListenerType, v3.RouteType, v3.SecretType, v3.ExtensionConfigurationType:
+	default:
+		// Only Envoy types are validated
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.stopped {
+		return
+	}
+	if replace {
+		v.reset(typeURL)
+	}
+	for _, n := range removed {
+		v.remove(typeURL, n)
+	}
+	for _, r := range resources {
+		m, err := r.UnmarshalNew()
+		if err != nil {
+			v.report(typeURL, checkProto, fmt.Sprintf("failed to decode: %v", err))
+			continue
+		}
+		if pv, ok := m.(interface{ Validate() error }); ok {
+			if err := pv.Validate(); err != nil {
+				v.report(typeURL, checkProto, fmt.Sprintf("%q: %v", resourceName(m), err))
+			}
+		}
+		v.add(m)
+	}
+	if v.timer != nil {
+		v.timer.Stop()
+	}
+	v.timer = time.AfterFunc(validationDelay, v.checkReferences)
+}
+
+// forget drops resources we have unsubscribed from.
+func (v *validator) forget(typeURL string, names []string) {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	for _, n := range names {
+		v.remove(typeURL, n)
+	}
+}
+
+func (v *validator) stop() {
+	if v == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.stopped = true
+	if v.timer != nil {
+		v.timer.Stop()
+	}
+}
+
+func (v *validator) reset(typeURL string) {
+	switch typeURL {
+	case v3.ClusterType:
+		v.edsClusters = map[string]bool{}
+	case v3.EndpointType:
+		v.endpoints = sets.New[string]()
+	case v3.ListenerType:
+		v.listenerRoutes = map[string][]string{}
+	case v3.RouteType:
+		v.routeClusters = map[string][]string{}
+	}
+}
+
+func (v *validator) remove(typeURL string, name string) {
+	switch typeURL {
+	case v3.ClusterType:
+		delete(v.edsClusters, name)
+	case v3.EndpointType:
+		v.endpoints.Delete(name)
+	case v3.ListenerType:
+		delete(v.listenerRoutes, name)
+	case v3.RouteType:
+		delete(v.routeClusters, name)
+	}
+}
+
+func (v *validator) add(m proto.Message) {
+	switch o := m.(type) {
+	case *cluster.Cluster:
+		v.edsClusters[o.Name] = o.GetType() == cluster.Cluster_EDS
+	case *endpoint.ClusterLoadAssignment:
+		v.endpoints.Insert(o.ClusterName)
+	case *listener.Listener:
+		routes := []string{}
+		for _, fc := range getFilterChains(o) {
+			for _, f := range fc.GetFilters() {
+				h := &hcm.HttpConnectionManager{}
+				if f.GetTypedConfig().UnmarshalTo(h) != nil {
+					continue
+				}
+				if r := h.GetRds().GetRouteConfigName(); r != "" {
+					routes = append(routes, r)
+				}
+			}
+		}
+		v.listenerRoutes[o.Name] = routes
+	case *route.RouteConfiguration:
+		clusters := []string{}
+		for _, vh := range o.GetVirtualHosts() {
+			for _, r := range vh.GetRoutes() {
+				if c := r.GetRoute().GetCluster(); c != "" {
+					clusters = append(clusters, c)
+				}
+				for _, wc := range r.GetRoute().GetWeightedClusters().GetClusters() {
+					clusters = append(clusters, wc.GetName())
+				}
+			}
+		}
+		v.routeClusters[o.Name] = clusters
+	}
+}
+
+// checkReferences verifies that all references between resources are satisfied. Violations are only reported
+// the first time they are seen.
+func (v *validator) checkReferences() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.stopped {
+		// The timer may have fired as the connection closed
+		return
+	}
+	found := sets.New[string]()
+	violation := func(typeURL, check, msg string) {
+		found.Insert(check + "/" + msg)
+		if !v.violations.Contains(check + "/" + msg) {
+			v.report(typeURL, check, msg)
+		}
+	}
+	// With on-demand clusters, routes reference clusters we have not subscribed to yet
+	if !v.onDemand {
+		for rc, clusters := range v.routeClusters {
+			for _, c := range clusters {
+				if _, f := v.edsClusters[c]; !f {
+					violation(v3.RouteType, checkRouteCluster, fmt.Sprintf("route %q references unknown cluster %q", rc, c))
+				}
+			}
+		}
+	}
+	for l, routes := range v.listenerRoutes {
+		for _, r := range routes {
+			if _, f := v.routeClusters[r]; !f {
+				violation(v3.ListenerType, checkListenerRoute, fmt.Sprintf("listener %q references unknown route %q", l, r))
+			}
+		}
+	}
+	for c, eds := range v.edsClusters {
+		if eds && !v.endpoints.Contains(c) {
+			violation(v3.ClusterType, checkClusterEndpoint, fmt.Sprintf("cluster %q has no endpoints", c))
+		}
+	}
+	v.violations = found
+}
+
+func (v *validator) report(typeURL string, check string, msg string) {
+	v.log.WithLabels("type", v3.GetShortType(typeURL), "check", check).Warnf("validation failed: %v", msg)
+	recordValidationError(v.nodeType, typeURL, check)
+}
+
+func resourceName(m proto.Message) string {
+	switch o := m.(type) {
+	case *endpoint.ClusterLoadAssignment:
+		return o.ClusterName
+	case interface{ GetName() string }:
+		return o.GetName()
+	}
+	return ""
+}
@@ -0,0 +1,76 @@
+package adsc
+
+import (
+	"testing"
+
+	route "github.com/envoyproxy/go-control-plane/envoy/config/route/v3"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+)
+
+func toAny(t *testing.T, m proto.Message) *anypb.Any {
+	t.Helper()
+	a, err := anypb.New(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// routeTo returns a route configuration with a single route to the cluster.
+func routeTo(name, cluster string) *route.RouteConfiguration {
+	return &route.RouteConfiguration{
+		Name: name,
+		VirtualHosts: []*route.VirtualHost{{
+			Name:    "vh",
+			Domains: []string{"*"},
+			Routes: []*route.Route{{
+				Match:  &route.RouteMatch{PathSpecifier: &route.RouteMatch_Prefix{Prefix: "/"}},
+				Action: &route.Route_Route{Route: &route.RouteAction{ClusterSpecifier: &route.RouteAction_Cluster{Cluster: cluster}}},
+			}},
+		}},
+	}
+}
+
+func TestValidatorRouteClusters(t *testing.T) {
+	tests := []struct {
+		name     string
+		onDemand bool
+		want     bool
+	}{
+		{name: "all clusters", want: true},
+		// Clusters are only subscribed to once called, so unknown clusters are expected
+		{name: "on demand", onDemand: true, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newValidator(true, "node", "sidecar", tt.onDemand)
+			defer v.stop()
+			v.observe(v3.RouteType, []*anypb.Any{toAny(t, routeTo("80", "outbound|80||missing"))}, nil, false)
+			v.checkReferences()
+			got := v.violations.Contains(checkRouteCluster + `/route "80" references unknown cluster "outbound|80||missing"`)
+			if got != tt.want {
+				t.Fatalf("got violation %v, want %v: %v", got, tt.want, v.violations)
+			}
+		})
+	}
+}
+
+func TestValidatorStopped(t *testing.T) {
+	v := newValidator(true, "node", "sidecar", false)
+	v.stop()
+	v.observe(v3.RouteType, []*anypb.Any{toAny(t, routeTo("80", "missing"))}, nil, false)
+	if v.timer != nil {
+		t.Fatal("observe after stop must not schedule a check")
+	}
+	if len(v.routeClusters) != 0 {
+		t.Fatalf("observe after stop must not record resources, got %v", v.routeClusters)
+	}
+	// A check already scheduled when the connection closed does nothing
+	v.routeClusters["80"] = []string{"missing"}
+	v.checkReferences()
+	if len(v.violations) != 0 {
+		t.Fatalf("got violations %v after stop", v.violations)
+	}
+}
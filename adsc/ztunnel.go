@@ -0,0 +1,182 @@
+package adsc
+
+import (
+	"context"
+	"maps"
+	"math/rand"
+	"sync"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	v3 "istio.io/istio/pilot/pkg/xds/v3"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/util/sets"
+	"istio.io/istio/pkg/workloadapi"
+	"istio.io/istio/pkg/workloadapi/security"
+)
+
+// ZtunnelConfig configures how ztunnel clients subscribe to addresses.
+type ZtunnelConfig struct {
+	// OnDemand subscribes to addresses only as they are looked up, as ztunnel does with on-demand XDS, rather
+	// than receiving every address in the mesh. Istiod additionally sends the workloads on the ztunnel's node.
+	OnDemand bool
+	// Lookups are addresses that may be looked up on demand, as "namespace/hostname" or "network/ip".
+	Lookups []string
+	// LookupInterval is the time between looking up each address, in a random order. If unset, all addresses
+	// are looked up at start.
+	LookupInterval time.Duration
+}
+
+const (
+	ztunnelWorkload      = "workload"
+	ztunnelService       = "service"
+	ztunnelAuthorization = "authorization"
+)
+
+// ztunnelState decodes the addresses and authorizations received by a ztunnel, tracking how many of each it has.
+type ztunnelState struct {
+	cfg  ZtunnelConfig
+	self string
+	log  *log.Scope
+
+	mu sync.Mutex
+	// kinds holds the kind of each resource, so removals can be counted
+	kinds  map[string]string
+	counts map[string]int
+	// subscribed holds the addresses we have looked up. Only used in on-demand mode.
+	subscribed sets.String
+}
+
+// newZtunnelState returns the state for a ztunnel. self is the ztunnel's own address, as "network/ip".
+func newZtunnelState(cfg ZtunnelConfig, nodeID, self string) *ztunnelState {
+	return &ztunnelState{
+		cfg:        cfg,
+		self:       self,
+		log:        scope.WithLabels("node", nodeID),
+		kinds:      map[string]string{},
+		counts:     map[string]int{},
+		subscribed: sets.New[string](),
+	}
+}
+
+// initialSubscriptions returns the names to subscribe to in the initial request for the type. Empty results in a
+// wildcard subscription.
+func (z *ztunnelState) initialSubscriptions(typeURL string) []string {
+	if typeURL != v3.AddressType || !z.cfg.OnDemand {
+		return nil
+	}
+	// Subscribing to our own address ensures the request is not treated as wildcard, even with nothing to look up
+	names := []string{z.self}
+	if z.cfg.LookupInterval == 0 {
+		names = append(names, z.cfg.Lookups...)
+	}
+	z.mu.Lock()
+	z.subscribed.InsertAll(names...)
+	z.mu.Unlock()
+	return names
+}
+
+// runLookups looks up each address in turn, until all are subscribed or the context ends.
+func (z *ztunnelState) runLookups(ctx context.Context, p *pauser) {
+	if !z.cfg.OnDemand || z.cfg.LookupInterval == 0 {
+		return
+	}
+	lookups := append([]string(nil), z.cfg.Lookups...)
+	rand.Shuffle(len(lookups), func(i, j int) {
+		lookups[i], lookups[j] = lookups[j], lookups[i]
+	})
+	t := time.NewTicker(z.cfg.LookupInterval)
+	defer t.Stop()
+	for _, l := range lookups {
+		if !waitTick(ctx, t) {
+			return
+		}
+		z.mu.Lock()
+		seen := z.subscribed.InsertContains(l)
+		z.mu.Unlock()
+		if seen {
+			continue
+		}
+		z.log.Debugf("looking up %v", l)
+		p.queue(intern(v3.AddressType), []IString{intern(l)}, nil)
+	}
+}
+
+// observe decodes a response, updating the resource counts.
+func (z *ztunnelState) observe(msg *discovery.DeltaDiscoveryResponse) {
+	if z == nil {
+		return
+	}
+	if msg.TypeUrl != v3.AddressType && msg.TypeUrl != v3.WorkloadAuthorizationType {
+		return
+	}
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	before := maps.Clone(z.counts)
+	for _, r := range msg.RemovedResources {
+		if k, f := z.kinds[r]; f {
+			z.counts[k]--
+			delete(z.kinds, r)
+		} else if z.subscribed.Contains(r) {
+			// For on-demand, lookups that do not exist are returned as removed
+			z.log.Debugf("address %v not found", r)
+		}
+	}
+	for _, r := range msg.Resources {
+		kind := z.decode(r)
+		if kind == "" {
+			continue
+		}
+		if old, f := z.kinds[r.Name]; f {
+			z.counts[old]--
+		}
+		z.kinds[r.Name] = kind
+		z.counts[kind]++
+	}
+	for _, k := range []string{ztunnelWorkload, ztunnelService, ztunnelAuthorization} {
+		recordZtunnelResources(k, z.counts[k]-before[k])
+	}
+	z.log.WithLabels("workloads", z.counts[ztunnelWorkload], "services", z.counts[ztunnelService],
+		"authorizations", z.counts[ztunnelAuthorization]).Debugf("updated state")
+}
+
+// close removes the resources held from the totals, once the connection is closed.
+func (z *ztunnelState) close() {
+	if z == nil {
+		return
+	}
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	for k, n := range z.counts {
+		recordZtunnelResources(k, -n)
+	}
+	z.counts = map[string]int{}
+	z.kinds = map[string]string{}
+}
+
+func (z *ztunnelState) decode(r *discovery.Resource) string {
+	switch r.GetResource().GetTypeUrl() {
+	case v3.AddressType:
+		a := &workloadapi.Address{}
+		if err := r.GetResource().UnmarshalTo(a); err != nil {
+			z.log.Warnf("failed to decode address %v: %v", r.Name, err)
+			return ""
+		}
+		switch a.GetType().(type) {
+		case *workloadapi.Address_Workload:
+			return ztunnelWorkload
+		case *workloadapi.Address_Service:
+			return ztunnelService
+		}
+	case v3.WorkloadAuthorizationType:
+		a := &security.Authorization{}
+		if err := r.GetResource().UnmarshalTo(a); err != nil {
+			z.log.Warnf("failed to decode authorization %v: %v", r.Name, err)
+			return ""
+		}
+		return ztunnelAuthorization
+	default:
+		z.log.Warnf("unexpected resource type %v for %v", r.GetResource().GetTypeUrl(), r.Name)
+	}
+	return ""
+}
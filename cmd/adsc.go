@@ -20,6 +20,7 @@ func init() {
 	adscCmd.PersistentFlags().DurationVar(&adscConfig.Delay, "delay", adscConfig.Delay, "delay between each connection")
 	adscCmd.PersistentFlags().IntVar(&adscConfig.Count, "count", adscConfig.Count, "number of adsc connections to make")
 	adscCmd.PersistentFlags().StringVar(&adscConfig.Namespace, "namespace", adscConfig.Namespace, "namespace of simulation")
+	adscCmd.PersistentFlags().StringVar(&adscConfig.Cluster, "cluster", adscConfig.Cluster, "cluster ID to report to Istiod (defaults to Kubernetes)")
 }
 
 var adscCmd = &cobra.Command{
@@ -31,6 +32,7 @@ var adscCmd = &cobra.Command{
 			return err
 		}
 		args.AdsConfig = adscConfig
+		args.Auth.ClusterID = adscConfig.Cluster
 		logConfig(args.AdsConfig)
 		return simulation.Adsc(args)
 	},
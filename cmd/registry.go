@@ -9,6 +9,7 @@ import (
 	"github.com/howardjohn/pilot-load/sims/reproducecluster"
 	"github.com/howardjohn/pilot-load/sims/victoriapush"
 	"github.com/howardjohn/pilot-load/sims/xdslatency"
+	"github.com/howardjohn/pilot-load/sims/xdsreplay"
 )
 
 var commands = []flag.CommandBuilder{
@@ -19,4 +20,5 @@ var commands = []flag.CommandBuilder{
 	adscimpersonate.Command,
 	cluster.Command,
 	victoriapush.Command,
+	xdsreplay.Command,
 }
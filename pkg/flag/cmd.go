@@ -1,8 +1,10 @@
 package flag
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"runtime/pprof"
 
 	"github.com/spf13/cobra"
@@ -10,10 +12,12 @@ import (
 	"istio.io/istio/pkg/log"
 	"sigs.k8s.io/yaml"
 
+	"github.com/howardjohn/pilot-load/adsc"
 	"github.com/howardjohn/pilot-load/pkg/kube"
 	"github.com/howardjohn/pilot-load/pkg/simulation"
 	"github.com/howardjohn/pilot-load/pkg/simulation/model"
 	"github.com/howardjohn/pilot-load/pkg/simulation/security"
+	"github.com/howardjohn/pilot-load/pkg/simulation/util"
 )
 
 type CommandBuilder = func(f *pflag.FlagSet) Command
@@ -38,16 +42,51 @@ func GetArgs() (model.Args, error) {
 	if auth == "" {
 		auth = security.DefaultAuthForAddress(pilotAddress)
 	}
+	sh, err := model.ParseShard(shard)
+	if err != nil {
+		return model.Args{}, err
+	}
+	util.SetIPShard(sh.Index, sh.Count)
+	nack := adsc.NackConfig{
+		Percent:      nackPercent,
+		TypeUrls:     nackTypes,
+		UntilVersion: nackUntil,
+		Detail:       nackDetail,
+	}
+	if nackResources != "" {
+		nack.Resources, err = regexp.Compile(nackResources)
+		if err != nil {
+			return model.Args{}, fmt.Errorf("invalid --nack-resources: %v", err)
+		}
+	}
+	if err := nack.Validate(); err != nil {
+		return model.Args{}, fmt.Errorf("invalid nack config: %v", err)
+	}
+	if err := onDemand.Validate(); err != nil {
+		return model.Args{}, fmt.Errorf("invalid on-demand config: %v", err)
+	}
+	switch processingDelay.Distribution {
+	case "", "uniform", "exponential":
+	default:
+		return model.Args{}, fmt.Errorf("invalid --processing-delay-distribution %q", processingDelay.Distribution)
+	}
 	authOpts := &security.AuthOptions{
 		Type:   auth,
 		Client: cl,
 	}
 	args := model.Args{
-		PilotAddress: pilotAddress,
-		DeltaXDS:     delta,
-		Metadata:     xdsMetadata,
-		Client:       cl,
-		Auth:         authOpts,
+		PilotAddress:    pilotAddress,
+		DeltaXDS:        delta,
+		Metadata:        xdsMetadata,
+		Client:          cl,
+		KubeQPS:         qps,
+		Auth:            authOpts,
+		Shard:           sh,
+		Nack:            nack,
+		ProcessingDelay: processingDelay,
+		Validate:        validate,
+		RecordDir:       recordDir,
+		OnDemand:        onDemand,
 	}
 	return args, nil
 }
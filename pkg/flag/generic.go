@@ -33,6 +33,8 @@ func RegisterShort[T Parseable](flags *pflag.FlagSet, val *T, name, short string
 		flags.BoolVarP(any(val).(*bool), name, short, d, description)
 	case int:
 		flags.IntVarP(any(val).(*int), name, short, d, description)
+	case float64:
+		flags.Float64VarP(any(val).(*float64), name, short, d, description)
 	case []string:
 		flags.StringSliceVarP(any(val).(*[]string), name, short, d, description)
 	case time.Duration:
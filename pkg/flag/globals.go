@@ -7,6 +7,7 @@ import (
 	"github.com/spf13/cobra"
 	"istio.io/istio/pkg/log"
 
+	"github.com/howardjohn/pilot-load/adsc"
 	"github.com/howardjohn/pilot-load/pkg/simulation/security"
 )
 
@@ -19,6 +20,20 @@ var (
 	loggingOptions = defaultLogOptions()
 
 	qps = 100000
+
+	shard = ""
+
+	nackPercent   = 0.0
+	nackTypes     = []string{}
+	nackResources = ""
+	nackUntil     = 0
+	nackDetail    = ""
+
+	processingDelay = adsc.ProcessingDelay{}
+
+	validate  = false
+	recordDir = ""
+	onDemand  = adsc.OnDemandConfig{}
 )
 
 func defaultLogOptions() *log.Options {
@@ -48,6 +63,34 @@ func AttachGlobalFlags(c *cobra.Command) {
 	c.PersistentFlags().StringToStringVarP(&xdsMetadata, "metadata", "m", xdsMetadata, "xds metadata")
 
 	c.PersistentFlags().BoolVar(&delta, "delta", delta, "use delta XDS")
+	c.PersistentFlags().Float64Var(&nackPercent, "nack-percent", nackPercent, "percent (0-100) of XDS responses to NACK")
+	c.PersistentFlags().StringSliceVar(&nackTypes, "nack-types", nackTypes,
+		"if set, only NACK XDS responses of these types. Accepts type URLs or short names (cds, lds, ...)")
+	c.PersistentFlags().StringVar(&nackResources, "nack-resources", nackResources,
+		"if set, only NACK XDS responses containing a resource with a name matching this regex")
+	c.PersistentFlags().IntVar(&nackUntil, "nack-until", nackUntil,
+		"if set, NACK XDS responses of each type until this version, which is ACKed along with all later versions")
+	c.PersistentFlags().StringVar(&nackDetail, "nack-detail", nackDetail, "error detail sent with NACKs")
+	c.PersistentFlags().DurationVar(&processingDelay.Fixed, "processing-delay", processingDelay.Fixed,
+		"delay before each XDS response is processed and ACKed, to simulate a slow client")
+	c.PersistentFlags().DurationVar(&processingDelay.Random, "processing-delay-random", processingDelay.Random,
+		"random delay added before each XDS response is processed; see --processing-delay-distribution")
+	c.PersistentFlags().StringVar(&processingDelay.Distribution, "processing-delay-distribution", processingDelay.Distribution,
+		"distribution of the random processing delay: uniform (0 to the value) or exponential (mean of the value)")
+	c.PersistentFlags().DurationVar(&processingDelay.PerMB, "processing-delay-per-mb", processingDelay.PerMB,
+		"delay added per MB of each XDS response")
+	c.PersistentFlags().IntVar(&processingDelay.MaxInFlight, "max-in-flight", processingDelay.MaxInFlight,
+		"maximum XDS responses received but not yet ACKed, per connection. Further responses are not read, applying backpressure")
+	c.PersistentFlags().BoolVar(&validate, "validate", validate,
+		"validate XDS responses, reporting invalid resources and broken references (such as routes to unknown clusters)")
+	c.PersistentFlags().Float64Var(&onDemand.Rate, "on-demand-rate", onDemand.Rate,
+		"if set, sidecars load clusters on demand (ODCDS), calling this many new services per minute. Requires --delta")
+	c.PersistentFlags().BoolVar(&onDemand.VirtualHosts, "on-demand-vhds", onDemand.VirtualHosts,
+		"with --on-demand-rate, also load the virtual host of each service called on demand (VHDS)")
+	c.PersistentFlags().StringVar(&recordDir, "record-dir", recordDir,
+		"if set, all XDS responses are recorded to a file per proxy in this directory, for use with xds-replay")
+	c.PersistentFlags().StringVar(&shard, "shard", shard,
+		"when running multiple instances, the shard (i/n) owned by this instance. Each instance simulates only its share of proxies.")
 
 	loggingOptions.AttachCobraFlags(c)
 	hiddenFlags := []string{
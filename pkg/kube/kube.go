@@ -3,6 +3,7 @@ package kube
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"reflect"
@@ -36,17 +37,22 @@ import (
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/client-go/util/retry"
+
+	"github.com/howardjohn/pilot-load/pkg/stats"
 )
 
 type Client struct {
 	kube.Client
 	ClusterName string
+	// fake is set for fake clients, which do not support subresources
+	fake bool
 }
 
 func NewFakeClient(kf kube.Client) *Client {
 	return &Client{
 		ClusterName: "fake",
 		Client:      kf,
+		fake:        true,
 	}
 }
 
@@ -169,10 +175,13 @@ func ApplyRealSSA[T controllers.Object](c *Client, o T) error {
 		Force:        ptr.Of(true),
 		FieldManager: "pilot-load",
 	}
+	t0 := time.Now()
 	err := patcher(context.TODO(), name, types.ApplyPatchType, b, opts)
 	if err != nil {
+		stats.Errors.Inc("apply")
 		return fmt.Errorf("failed to ssa %s/%s/%s: %v", t, name, ns, err)
 	}
+	stats.ApplyLatency.Record(time.Since(t0))
 	scope.Debugf("fast ssa resource: %s/%s/%s", t, name, ns)
 	if hasStatus(c, o) {
 		scope.Debugf("fast ssa resource status: %s/%s.%s", t, name, ns)
@@ -198,7 +207,17 @@ func ApplyStatusRealSSA[T controllers.Object](c *Client, o T) error {
 		Force:        ptr.Of(true),
 		FieldManager: "pilot-load",
 	}
-	_, err := cl.Patch(context.TODO(), name, types.ApplyPatchType, b, opts, "status")
+	patchType := types.ApplyPatchType
+	if c.fake {
+		// Fake clients ignore the subresource and apply the whole object, so only merge the status
+		var err error
+		if b, err = statusMergePatch(b); err != nil {
+			return err
+		}
+		patchType = types.MergePatchType
+		opts = metav1.PatchOptions{}
+	}
+	_, err := cl.Patch(context.TODO(), name, patchType, b, opts, "status")
 	if err != nil {
 		return fmt.Errorf("failed to ssa %s/%s/%s: %v", t, name, ns, err)
 	}
@@ -206,6 +225,17 @@ func ApplyStatusRealSSA[T controllers.Object](c *Client, o T) error {
 	return nil
 }
 
+// statusMergePatch returns a merge patch setting only the status of the encoded object.
+func statusMergePatch(b []byte) ([]byte, error) {
+	var obj struct {
+		Status json.RawMessage `json:"status"`
+	}
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+	return json.Marshal(obj)
+}
+
 func ApplyStatus[T controllers.Object](c *Client, o T) error {
 	name := o.GetName()
 	ns := o.GetNamespace()
@@ -1,6 +1,7 @@
 package kube
 
 import (
+	"context"
 	"testing"
 
 	"istio.io/api/meta/v1alpha1"
@@ -9,6 +10,7 @@ import (
 	"istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/kube/controllers"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestCreate(t *testing.T) {
@@ -64,3 +66,32 @@ func TestGetStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestApplyStatusRealSSAFake(t *testing.T) {
+	c := NewFakeClient(kube.NewFakeClient())
+	p := &v1.Pod{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{Name: "pod", Namespace: "ns"},
+		Spec:       v1.PodSpec{NodeName: "node", Containers: []v1.Container{{Name: "app"}}},
+	}
+	if err := ApplyRealSSA(c, p); err != nil {
+		t.Fatal(err)
+	}
+	status := p.DeepCopy()
+	status.Spec = v1.PodSpec{}
+	status.Status.Phase = v1.PodRunning
+	if err := ApplyStatusRealSSA(c, status); err != nil {
+		t.Fatal(err)
+	}
+	got, err := c.Kube().CoreV1().Pods("ns").Get(context.Background(), "pod", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Status.Phase != v1.PodRunning {
+		t.Fatalf("got phase %v, want running", got.Status.Phase)
+	}
+	// Like the API server, only the status is updated
+	if got.Spec.NodeName != "node" {
+		t.Fatalf("got spec %+v, want it unchanged", got.Spec)
+	}
+}
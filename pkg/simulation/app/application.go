@@ -1,12 +1,16 @@
 package app
 
 import (
+	"fmt"
+
 	"istio.io/istio/pkg/log"
 	"istio.io/istio/pkg/maps"
+	"istio.io/istio/pkg/ptr"
 	"k8s.io/apimachinery/pkg/util/rand"
 
 	"github.com/howardjohn/pilot-load/pkg/simulation/config"
 	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+	"github.com/howardjohn/pilot-load/pkg/stats"
 )
 
 type ApplicationSpec struct {
@@ -14,8 +18,13 @@ type ApplicationSpec struct {
 	Node                func() string
 	Namespace           string
 	ServiceAccount      string
+	Cluster             string
+	Network             string
 	Instances           int
 	Type                model.AppType
+	WorkloadKind        model.WorkloadKind
+	EndpointSlice       EndpointSliceConfig
+	Services            []ServiceConfig
 	TemplateDefinitions model.TemplateDefinitions
 	Templates           []model.ConfigTemplate
 	Labels              map[string]string
@@ -24,7 +33,9 @@ type ApplicationSpec struct {
 type Application struct {
 	Spec          *ApplicationSpec
 	pods          []*Pod
-	service       *Service
+	workload      *Workload
+	endpoints     *EndpointSlices
+	services      []*Service
 	kgateways     []*config.KubeGateway
 	workloadEntry *config.WorkloadEntry
 	workloadGroup *config.WorkloadGroup
@@ -53,7 +64,11 @@ func NewApplication(s ApplicationSpec) *Application {
 		if _, f := cfg[config.Name]; !f {
 			cfg[config.Name] = s.App
 		}
+		if _, f := cfg[config.ServiceAccount]; !f {
+			cfg[config.ServiceAccount] = s.ServiceAccount
+		}
 		w.configs = append(w.configs, config.NewTemplated(config.TemplatedSpec{
+			Name:     tmpl.Name,
 			Template: s.TemplateDefinitions.Get(tmpl.Name),
 			Config:   cfg,
 			Refresh:  tmpl.Refresh,
@@ -90,17 +105,42 @@ func NewApplication(s ApplicationSpec) *Application {
 		return w
 	}
 
-	// Currently we never use Deployment since its pretty slow - create Pods manually instead
-	for i := 0; i < s.Instances; i++ {
-		w.pods = append(w.pods, w.makePod())
-	}
+	w.services = newServices(s)
 
-	w.service = NewService(ServiceSpec{
-		App:       s.App,
-		Namespace: s.Namespace,
-		Labels:    s.Labels,
-		Waypoint:  s.Type == model.WaypointType,
-	})
+	if s.WorkloadKind == model.EndpointSliceKind {
+		var sliceServices []SliceService
+		for _, svc := range w.services {
+			if svc.HasEndpoints() {
+				sliceServices = append(sliceServices, SliceService{Name: svc.Name(), Ports: svc.Ports()})
+			}
+		}
+		w.endpoints = NewEndpointSlices(EndpointSliceSpec{
+			App:           s.App,
+			Namespace:     s.Namespace,
+			Node:          s.Node,
+			Endpoints:     s.Instances,
+			MaxEndpoints:  s.EndpointSlice.MaxEndpoints,
+			Flap:          s.EndpointSlice.Flap,
+			TopologyHints: s.EndpointSlice.TopologyHints,
+			Services:      sliceServices,
+		})
+	} else if s.WorkloadKind.IsController() {
+		w.workload = NewWorkload(WorkloadSpec{
+			App:            s.App,
+			Namespace:      s.Namespace,
+			ServiceAccount: s.ServiceAccount,
+			Cluster:        s.Cluster,
+			Network:        s.Network,
+			Kind:           s.WorkloadKind,
+			Replicas:       s.Instances,
+			AppType:        s.Type,
+		})
+	} else {
+		// By default, create Pods manually since controllers are pretty slow
+		for i := 0; i < s.Instances; i++ {
+			w.pods = append(w.pods, w.makePod())
+		}
+	}
 
 	if s.Type == model.WaypointType {
 		gw := config.NewKubeGateway(config.KubeGatewaySpec{
@@ -114,6 +154,61 @@ func NewApplication(s ApplicationSpec) *Application {
 	return w
 }
 
+// ServiceKeys returns the services of the application, as "namespace/hostname".
+func (w *Application) ServiceKeys() []string {
+	return serviceKeys(w.services)
+}
+
+// ServiceKeysFor returns the services an application with the spec would have, without creating it.
+func ServiceKeysFor(s ApplicationSpec) []string {
+	if s.Type == model.ExternalType || s.Type == model.VMType {
+		return []string{}
+	}
+	return serviceKeys(newServices(s))
+}
+
+func newServices(s ApplicationSpec) []*Service {
+	services := s.Services
+	if len(services) == 0 || s.Type == model.WaypointType {
+		services = []ServiceConfig{{}}
+	}
+	res := make([]*Service, 0, len(services))
+	for _, svc := range services {
+		res = append(res, NewService(ServiceSpec{
+			App:          s.App,
+			Namespace:    s.Namespace,
+			Labels:       s.Labels,
+			Waypoint:     s.Type == model.WaypointType,
+			Selectorless: s.WorkloadKind == model.EndpointSliceKind,
+			Config:       svc,
+		}))
+	}
+	return res
+}
+
+func serviceKeys(services []*Service) []string {
+	keys := make([]string, 0, len(services))
+	for _, svc := range services {
+		keys = append(keys, fmt.Sprintf("%s/%s.%s.svc.cluster.local", svc.Spec.Namespace, svc.Name(), svc.Spec.Namespace))
+	}
+	return keys
+}
+
+// ScopeSidecar restricts the egress of the application's sidecars to the hosts, as "namespace/hostname",
+// with a generated Sidecar.
+func (w *Application) ScopeSidecar(hosts []string) {
+	w.configs = append(w.configs, config.NewTemplated(config.TemplatedSpec{
+		Name:     "sidecar-scoped",
+		Template: w.Spec.TemplateDefinitions.Get("sidecar-scoped"),
+		Config: map[string]any{
+			config.Namespace: w.Spec.Namespace,
+			config.Name:      w.Spec.App,
+			"Hosts":          hosts,
+		},
+		Refresh: ptr.Of(false),
+	}))
+}
+
 func (w *Application) GetConfigs() []model.RefreshableSimulation {
 	sims := []model.RefreshableSimulation{}
 	if w.workloadEntry != nil {
@@ -130,6 +225,8 @@ func (w *Application) makePod() *Pod {
 	s := w.Spec
 	return NewPod(PodSpec{
 		ServiceAccount: s.ServiceAccount,
+		Cluster:        s.Cluster,
+		Network:        s.Network,
 		Node:           s.Node(),
 		App:            s.App,
 		Namespace:      s.Namespace,
@@ -143,8 +240,8 @@ func (w *Application) getSims() []model.Simulation {
 	for _, cfg := range w.configs {
 		sims = append(sims, cfg)
 	}
-	if w.service != nil {
-		sims = append(sims, w.service)
+	for _, svc := range w.services {
+		sims = append(sims, svc)
 	}
 	if w.serviceEntry != nil {
 		sims = append(sims, w.serviceEntry)
@@ -158,6 +255,12 @@ func (w *Application) getSims() []model.Simulation {
 	for _, gw := range w.kgateways {
 		sims = append(sims, gw)
 	}
+	if w.workload != nil {
+		sims = append(sims, w.workload)
+	}
+	if w.endpoints != nil {
+		sims = append(sims, w.endpoints)
+	}
 	for _, p := range w.pods {
 		sims = append(sims, p)
 	}
@@ -165,7 +268,11 @@ func (w *Application) getSims() []model.Simulation {
 }
 
 func (w *Application) Run(ctx model.Context) (err error) {
-	return model.AggregateSimulation{Simulations: w.getSims()}.RunParallel(ctx)
+	if err := (model.AggregateSimulation{Simulations: w.getSims()}.RunParallel(ctx)); err != nil {
+		return err
+	}
+	stats.Created.Inc("application")
+	return nil
 }
 
 func (w *Application) Cleanup(ctx model.Context) error {
@@ -173,7 +280,12 @@ func (w *Application) Cleanup(ctx model.Context) error {
 }
 
 func (w *Application) Refresh(ctx model.Context) (string, error) {
-	// TODO: implement for Deployment
+	if w.workload != nil {
+		return w.workload.Refresh(ctx)
+	}
+	if w.endpoints != nil {
+		return w.endpoints.Refresh(ctx)
+	}
 	if len(w.pods) == 0 {
 		return "skipped, no pods", nil
 	}
@@ -198,11 +310,34 @@ func (w *Application) Refresh(ctx model.Context) (string, error) {
 	return newPod.Spec.Namespace + "/" + newPod.Name(), nil
 }
 
+// Replicas returns the current number of pods (or endpoints) of the application.
+func (w *Application) Replicas() int {
+	if w.workload != nil {
+		return w.workload.Spec.Replicas
+	}
+	if w.endpoints != nil {
+		return len(w.endpoints.endpoints)
+	}
+	return len(w.pods)
+}
+
 func (w *Application) Scale(ctx model.Context, delta int) error {
+	if w.workload != nil {
+		return w.workload.Scale(ctx, delta)
+	}
+	if w.endpoints != nil {
+		return w.endpoints.Scale(ctx, delta)
+	}
 	return w.ScaleTo(ctx, len(w.pods)+delta)
 }
 
 func (w *Application) ScaleTo(ctx model.Context, n int) error {
+	if w.workload != nil {
+		return w.workload.ScaleTo(ctx, n)
+	}
+	if w.endpoints != nil {
+		return w.endpoints.ScaleTo(ctx, n)
+	}
 	log.Infof("%v: scaling pod from %d -> %d", w.Spec.App, len(w.pods), n)
 	for n < len(w.pods) && n >= 0 {
 		i := 0
@@ -0,0 +1,234 @@
+package app
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/ptr"
+	v1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/howardjohn/pilot-load/pkg/kube"
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+	"github.com/howardjohn/pilot-load/pkg/simulation/util"
+)
+
+// EndpointSliceConfig configures applications using the EndpointSlice workload kind.
+type EndpointSliceConfig struct {
+	// MaxEndpoints is the number of endpoints per slice. Defaults to 100, matching the Kubernetes controller.
+	MaxEndpoints int `json:"maxEndpoints,omitempty"`
+	// Flap, if set, will make refreshes toggle readiness of an endpoint rather than replacing it.
+	Flap bool `json:"flap,omitempty"`
+	// TopologyHints, if set, adds zone hints to each endpoint.
+	TopologyHints bool `json:"topologyHints,omitempty"`
+}
+
+type EndpointSliceSpec struct {
+	App       string
+	Namespace string
+	Node      func() string
+	Endpoints int
+	// MaxEndpoints is the number of endpoints per slice. Defaults to 100, matching the Kubernetes controller.
+	MaxEndpoints int
+	// Flap, if set, will make refreshes toggle readiness of an endpoint rather than replacing it.
+	Flap          bool
+	TopologyHints bool
+	// Services to write slices for. Each service gets its own slices, with the same endpoints.
+	Services []SliceService
+}
+
+type SliceService struct {
+	Name  string
+	Ports []v1.ServicePort
+}
+
+// EndpointSlices writes EndpointSlices directly for a service, without any backing pods.
+// This allows simulating a large number of endpoints without the cost of creating pods.
+type EndpointSlices struct {
+	Spec      *EndpointSliceSpec
+	endpoints []*endpoint
+	// slices is the number of slices currently written, so we know which to remove when scaling down
+	slices int
+}
+
+type endpoint struct {
+	ip    string
+	node  string
+	ready bool
+}
+
+var (
+	_ model.Simulation            = &EndpointSlices{}
+	_ model.ScalableSimulation    = &EndpointSlices{}
+	_ model.RefreshableSimulation = &EndpointSlices{}
+)
+
+func NewEndpointSlices(s EndpointSliceSpec) *EndpointSlices {
+	if s.MaxEndpoints <= 0 {
+		s.MaxEndpoints = 100
+	}
+	e := &EndpointSlices{Spec: &s}
+	for range s.Endpoints {
+		e.endpoints = append(e.endpoints, e.makeEndpoint())
+	}
+	return e
+}
+
+func (e *EndpointSlices) makeEndpoint() *endpoint {
+	return &endpoint{
+		ip:    util.GetIP(),
+		node:  e.Spec.Node(),
+		ready: true,
+	}
+}
+
+func (e *EndpointSlices) Run(ctx model.Context) error {
+	return e.sync(ctx)
+}
+
+func (e *EndpointSlices) Cleanup(ctx model.Context) error {
+	for _, svc := range e.Spec.Services {
+		for i := range e.slices {
+			if err := kube.Delete(ctx.Client, e.getSlice(svc, i)); err != nil {
+				return err
+			}
+		}
+	}
+	e.slices = 0
+	return nil
+}
+
+func (e *EndpointSlices) Refresh(ctx model.Context) (string, error) {
+	if len(e.endpoints) == 0 {
+		return "skipped, no endpoints", nil
+	}
+	i := rand.Intn(len(e.endpoints))
+	ep := e.endpoints[i]
+	info := ""
+	if e.Spec.Flap {
+		ep.ready = !ep.ready
+		info = fmt.Sprintf("%s/%s endpoint %s ready=%v", e.Spec.Namespace, e.Spec.App, ep.ip, ep.ready)
+	} else {
+		e.endpoints[i] = e.makeEndpoint()
+		info = fmt.Sprintf("%s/%s endpoint %s -> %s", e.Spec.Namespace, e.Spec.App, ep.ip, e.endpoints[i].ip)
+	}
+	// Only the slices holding the endpoint changed
+	for _, svc := range e.Spec.Services {
+		if err := kube.ApplyRealSSA(ctx.Client, e.getSlice(svc, i/e.Spec.MaxEndpoints)); err != nil {
+			return "", err
+		}
+	}
+	return info, nil
+}
+
+func (e *EndpointSlices) Scale(ctx model.Context, delta int) error {
+	return e.ScaleTo(ctx, len(e.endpoints)+delta)
+}
+
+func (e *EndpointSlices) ScaleTo(ctx model.Context, n int) error {
+	log.Infof("%v: scaling endpoints from %d -> %d", e.Spec.App, len(e.endpoints), n)
+	n = max(n, 0)
+	for n > len(e.endpoints) {
+		e.endpoints = append(e.endpoints, e.makeEndpoint())
+	}
+	e.endpoints = e.endpoints[:n]
+	return e.sync(ctx)
+}
+
+// addEndpoints adds n endpoints, which are not ready until passed to replaceEndpoints.
+func (e *EndpointSlices) addEndpoints(ctx model.Context, n int) ([]*endpoint, error) {
+	added := make([]*endpoint, 0, n)
+	for range n {
+		ep := e.makeEndpoint()
+		ep.ready = false
+		added = append(added, ep)
+	}
+	e.endpoints = append(e.endpoints, added...)
+	return added, e.sync(ctx)
+}
+
+// replaceEndpoints marks the ready endpoints as ready, and removes the old endpoints.
+func (e *EndpointSlices) replaceEndpoints(ctx model.Context, ready []*endpoint, old []*endpoint) error {
+	for _, ep := range ready {
+		ep.ready = true
+	}
+	e.endpoints = slices.DeleteFunc(e.endpoints, func(ep *endpoint) bool {
+		return slices.Contains(old, ep)
+	})
+	return e.sync(ctx)
+}
+
+// sync writes all slices, and removes any that are no longer needed.
+func (e *EndpointSlices) sync(ctx model.Context) error {
+	want := (len(e.endpoints) + e.Spec.MaxEndpoints - 1) / e.Spec.MaxEndpoints
+	for _, svc := range e.Spec.Services {
+		for i := range want {
+			if err := kube.ApplyRealSSA(ctx.Client, e.getSlice(svc, i)); err != nil {
+				return err
+			}
+		}
+		for i := want; i < e.slices; i++ {
+			if err := kube.Delete(ctx.Client, e.getSlice(svc, i)); err != nil {
+				return err
+			}
+		}
+	}
+	e.slices = want
+	return nil
+}
+
+func (e *EndpointSlices) getSlice(svc SliceService, i int) *discoveryv1.EndpointSlice {
+	s := e.Spec
+	start := min(i*s.MaxEndpoints, len(e.endpoints))
+	end := min(start+s.MaxEndpoints, len(e.endpoints))
+	endpoints := make([]discoveryv1.Endpoint, 0, end-start)
+	for _, ep := range e.endpoints[start:end] {
+		kep := discoveryv1.Endpoint{
+			Addresses: []string{ep.ip},
+			Conditions: discoveryv1.EndpointConditions{
+				Ready:       ptr.Of(ep.ready),
+				Serving:     ptr.Of(ep.ready),
+				Terminating: ptr.Of(false),
+			},
+			NodeName: ptr.Of(ep.node),
+			Zone:     ptr.Of("zone"),
+		}
+		if s.TopologyHints {
+			kep.Hints = &discoveryv1.EndpointHints{ForZones: []discoveryv1.ForZone{{Name: "zone"}}}
+		}
+		endpoints = append(endpoints, kep)
+	}
+	ports := make([]discoveryv1.EndpointPort, 0, len(svc.Ports))
+	for _, p := range svc.Ports {
+		protocol := p.Protocol
+		if protocol == "" {
+			protocol = v1.ProtocolTCP
+		}
+		port := p.TargetPort.IntVal
+		if port == 0 {
+			port = p.Port
+		}
+		ports = append(ports, discoveryv1.EndpointPort{
+			Name:        ptr.Of(p.Name),
+			Port:        ptr.Of(port),
+			Protocol:    ptr.Of(protocol),
+			AppProtocol: p.AppProtocol,
+		})
+	}
+	return &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", svc.Name, i),
+			Namespace: s.Namespace,
+			Labels: map[string]string{
+				discoveryv1.LabelServiceName: svc.Name,
+				discoveryv1.LabelManagedBy:   "pilot-load",
+			},
+		},
+		AddressType: discoveryv1.AddressTypeIPv4,
+		Endpoints:   endpoints,
+		Ports:       ports,
+	}
+}
@@ -16,16 +16,20 @@ import (
 	"github.com/howardjohn/pilot-load/pkg/simulation/model"
 	"github.com/howardjohn/pilot-load/pkg/simulation/util"
 	"github.com/howardjohn/pilot-load/pkg/simulation/xds"
+	"github.com/howardjohn/pilot-load/pkg/stats"
 )
 
 type PodSpec struct {
 	ServiceAccount string
-	Node           string
-	App            string
-	Namespace      string
-	UID            string
-	IP             string
-	AppType        model.AppType
+	// Cluster ID the pod is in. Defaults to "Kubernetes"
+	Cluster   string
+	Network   string
+	Node      string
+	App       string
+	Namespace string
+	UID       string
+	IP        string
+	AppType   model.AppType
 }
 
 type Pod struct {
@@ -75,6 +79,7 @@ func (p *Pod) Run(ctx model.Context) (err error) {
 				terr = fmt.Errorf("failed to apply pod: %v", err)
 			} else {
 				p.created = true
+				stats.Created.Inc("pod")
 				break
 			}
 		}
@@ -85,15 +90,16 @@ func (p *Pod) Run(ctx model.Context) (err error) {
 
 	if p.Spec.AppType.HasProxy() {
 		p.xds = &xds.Simulation{
-			Labels:    pod.Labels,
-			Namespace: pod.Namespace,
-			Name:      pod.Name,
-			IP:        p.Spec.IP,
-			AppType:   p.Spec.AppType,
-			// TODO: multicluster
-			Cluster:  "Kubernetes",
-			GrpcOpts: ctx.Args.Auth.GrpcOptions(p.Spec.ServiceAccount, p.Spec.Namespace),
-			Delta:    ctx.Args.DeltaXDS,
+			Labels:         pod.Labels,
+			Namespace:      pod.Namespace,
+			ServiceAccount: p.Spec.ServiceAccount,
+			Name:           pod.Name,
+			IP:             p.Spec.IP,
+			AppType:        p.Spec.AppType,
+			Cluster:        p.Spec.Cluster,
+			Network:        p.Spec.Network,
+			GrpcOpts:       ctx.Args.Auth.GrpcOptions(p.Spec.ServiceAccount, p.Spec.Namespace),
+			Delta:          ctx.Args.DeltaXDS,
 		}
 		return p.xds.Run(ctx)
 	} else {
@@ -120,15 +126,28 @@ func (p *Pod) Name() string {
 
 func (p *Pod) getPod() *v1.Pod {
 	s := p.Spec
+	tmpl := podTemplate(s.App, s.ServiceAccount, s.AppType)
+	tmpl.Name = p.Name()
+	tmpl.Namespace = s.Namespace
+	// Schedule ourselves, kube scheduler is slow. TODO: make it optional?
+	tmpl.Spec.NodeName = s.Node
+	return &v1.Pod{
+		ObjectMeta: tmpl.ObjectMeta,
+		Spec:       tmpl.Spec,
+	}
+}
+
+// podTemplate builds the pod for an application, shared between pods we create directly and those created by controllers.
+func podTemplate(app, serviceAccount string, appType model.AppType) v1.PodTemplateSpec {
 	labels := map[string]string{
-		"app":                     s.App,
+		"app":                     app,
 		"owner":                   "pilot-load",
 		"sidecar.istio.io/inject": "false",
 	}
-	if p.Spec.AppType == model.SidecarType {
+	if appType == model.SidecarType {
 		labels["sidecar.istio.io/inject"] = "true"
 	}
-	if p.Spec.AppType == model.WaypointType {
+	if appType == model.WaypointType {
 		// Make sure we don't mark the waypoint as having a waypoint
 		labels["gateway.istio.io/managed"] = "istio.io-mesh-controller"
 	}
@@ -136,25 +155,21 @@ func (p *Pod) getPod() *v1.Pod {
 	annotations := map[string]string{
 		"prometheus.io/scrape": "false",
 	}
-	if p.Spec.AppType == model.AmbientType {
+	if appType == model.AmbientType {
 		annotations["ambient.istio.io/redirection"] = "enabled"
 	}
-	return &v1.Pod{
+	return v1.PodTemplateSpec{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:        p.Name(),
-			Namespace:   s.Namespace,
 			Labels:      labels,
 			Annotations: annotations,
 		},
 		Spec: v1.PodSpec{
 			TerminationGracePeriodSeconds: ptr.Of(int64(0)),
-			ServiceAccountName:            s.ServiceAccount,
+			ServiceAccountName:            serviceAccount,
 			Containers: []v1.Container{{
 				Name:  "app",
 				Image: "fake",
 			}},
-			// Schedule ourselves, kube scheduler is slow. TODO: make it optional?
-			NodeName: s.Node,
 			NodeSelector: map[string]string{
 				"pilot-load.istio.io/node": "fake",
 			},
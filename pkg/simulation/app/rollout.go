@@ -0,0 +1,176 @@
+package app
+
+import (
+	"fmt"
+	"slices"
+
+	"istio.io/istio/pkg/log"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+)
+
+// RolloutConfig configures a rolling update, with the same semantics as a Deployment's.
+type RolloutConfig struct {
+	// MaxSurge is the number or percent of pods created above the desired count. Defaults to 25%.
+	MaxSurge *intstr.IntOrString `json:"maxSurge,omitempty"`
+	// MaxUnavailable is the number or percent of pods that may be unavailable. Defaults to 25%.
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+	// ReadyDelay is how long new pods take to become ready, before old pods are removed.
+	ReadyDelay model.Duration `json:"readyDelay,omitempty"`
+}
+
+var defaultRolloutPercent = intstr.FromString("25%")
+
+// Rollout is an in progress rolling update of an application, replacing all of its pods (or endpoints) in batches.
+// Each step is short, so callers can interleave other changes and wait for readiness between them.
+type Rollout struct {
+	app *Application
+	old []*Pod
+	// oldEndpoints and newEndpoints are used instead of pods for EndpointSlice applications
+	oldEndpoints []*endpoint
+	newEndpoints []*endpoint
+	desired      int
+	// replicas is the count after the last step, so changes made by others in between can be detected
+	replicas    int
+	surge       int
+	unavailable int
+	// delegated is set when the application's controller performs the rollout itself
+	delegated bool
+	done      bool
+}
+
+// StartRollout begins a rolling update of the application's pods. Controller backed applications are
+// restarted instead, so the controller performs the rollout, with the configured limits for Deployments.
+func (w *Application) StartRollout(cfg RolloutConfig) (*Rollout, error) {
+	r := &Rollout{app: w}
+	if w.workload != nil {
+		w.workload.SetRollingUpdate(orDefault(cfg.MaxSurge), orDefault(cfg.MaxUnavailable))
+		r.delegated = true
+		return r, nil
+	}
+	r.desired = w.Replicas()
+	var err error
+	r.surge, err = intstr.GetScaledValueFromIntOrPercent(orDefault(cfg.MaxSurge), r.desired, true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maxSurge: %v", err)
+	}
+	r.unavailable, err = intstr.GetScaledValueFromIntOrPercent(orDefault(cfg.MaxUnavailable), r.desired, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid maxUnavailable: %v", err)
+	}
+	if r.surge == 0 && r.unavailable == 0 {
+		// Same as Deployments, to ensure progress
+		r.surge = 1
+	}
+	if w.endpoints != nil {
+		r.oldEndpoints = slices.Clone(w.endpoints.endpoints)
+	} else {
+		r.old = slices.Clone(w.pods)
+	}
+	r.replicas = r.desired
+	r.done = r.desired == 0
+	return r, nil
+}
+
+func orDefault(v *intstr.IntOrString) *intstr.IntOrString {
+	if v == nil {
+		return &defaultRolloutPercent
+	}
+	return v
+}
+
+// prune accounts for other changes, such as scaling or refreshes, since the last step. Old pods (or endpoints) they
+// removed are dropped, and scaling changes the desired count, as it would for a Deployment. It returns the number
+// of old pods remaining. Once none remain and the desired count is met, the rollout is done.
+func (r *Rollout) prune() int {
+	current := r.app.Replicas()
+	r.desired = max(r.desired+current-r.replicas, 0)
+	r.replicas = current
+	if r.app.endpoints != nil {
+		eps := r.app.endpoints.endpoints
+		r.oldEndpoints = slices.DeleteFunc(r.oldEndpoints, func(ep *endpoint) bool { return !slices.Contains(eps, ep) })
+		r.newEndpoints = slices.DeleteFunc(r.newEndpoints, func(ep *endpoint) bool { return !slices.Contains(eps, ep) })
+	} else {
+		r.old = slices.DeleteFunc(r.old, func(p *Pod) bool { return !slices.Contains(r.app.pods, p) })
+	}
+	remaining := len(r.old) + len(r.oldEndpoints)
+	r.done = remaining == 0 && current >= r.desired
+	return remaining
+}
+
+// ScaleUp creates new pods, up to the surge limit, returning the number created.
+func (r *Rollout) ScaleUp(ctx model.Context) (int, error) {
+	if r.done {
+		return 0, nil
+	}
+	if r.delegated {
+		r.done = true
+		_, err := r.app.Refresh(ctx)
+		return 0, err
+	}
+	remaining := r.prune()
+	if r.done {
+		return 0, nil
+	}
+	current := r.app.Replicas()
+	// Only the new pods still present count towards the desired count, so pods removed by other changes are replaced
+	n := min(r.desired+r.surge-current, r.desired-(current-remaining))
+	if n <= 0 {
+		return 0, nil
+	}
+	if r.app.endpoints != nil {
+		added, err := r.app.endpoints.addEndpoints(ctx, n)
+		r.newEndpoints = append(r.newEndpoints, added...)
+		r.replicas = r.app.Replicas()
+		return n, err
+	}
+	for range n {
+		pod := r.app.makePod()
+		r.app.pods = append(r.app.pods, pod)
+		r.replicas++
+		if err := pod.Run(ctx); err != nil {
+			return 0, err
+		}
+	}
+	return n, nil
+}
+
+// ScaleDown removes old pods, keeping at least the desired count less the unavailable limit, assuming new pods
+// are ready. It returns the number removed.
+func (r *Rollout) ScaleDown(ctx model.Context) (int, error) {
+	if r.done {
+		return 0, nil
+	}
+	remaining := r.prune()
+	n := max(min(r.app.Replicas()-(r.desired-r.unavailable), remaining), 0)
+	if r.app.endpoints != nil {
+		old := r.oldEndpoints[:n]
+		r.oldEndpoints = r.oldEndpoints[n:]
+		ready := r.newEndpoints
+		r.newEndpoints = nil
+		err := r.app.endpoints.replaceEndpoints(ctx, ready, old)
+		r.replicas = r.app.Replicas()
+		if err != nil {
+			return 0, err
+		}
+	} else {
+		for _, old := range r.old[:n] {
+			i := slices.Index(r.app.pods, old)
+			r.app.pods = slices.Delete(r.app.pods, i, i+1)
+			r.replicas--
+			if err := old.Cleanup(ctx); err != nil {
+				return 0, err
+			}
+		}
+		r.old = r.old[n:]
+	}
+	remaining = r.prune()
+	log.Debugf("%v: rollout has %d old replicas remaining", r.app.Spec.App, remaining)
+	return n, nil
+}
+
+// Done returns true once all pods have been replaced.
+func (r *Rollout) Done() bool {
+	return r.done
+}
@@ -0,0 +1,220 @@
+package app
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"istio.io/istio/pkg/kube"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	pkube "github.com/howardjohn/pilot-load/pkg/kube"
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+)
+
+func testContext() model.Context {
+	return model.Context{Context: context.Background(), Client: pkube.NewFakeClient(kube.NewFakeClient())}
+}
+
+func testApplication(t *testing.T, ctx model.Context, kind model.WorkloadKind, replicas int) *Application {
+	spec := &ApplicationSpec{
+		App:       "app",
+		Namespace: "ns",
+		Node:      func() string { return "node" },
+		Type:      model.PlainType,
+	}
+	a := &Application{Spec: spec}
+	if kind == model.EndpointSliceKind {
+		a.endpoints = NewEndpointSlices(EndpointSliceSpec{
+			App:       spec.App,
+			Namespace: spec.Namespace,
+			Node:      spec.Node,
+			Endpoints: replicas,
+			Services:  []SliceService{{Name: spec.App}},
+		})
+		if err := a.endpoints.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return a
+	}
+	if err := a.ScaleTo(ctx, replicas); err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+// members returns the identity of each current pod (or endpoint).
+func members(a *Application) []any {
+	var res []any
+	if a.endpoints != nil {
+		for _, ep := range a.endpoints.endpoints {
+			res = append(res, ep)
+		}
+		return res
+	}
+	for _, p := range a.pods {
+		res = append(res, p)
+	}
+	return res
+}
+
+func TestRollout(t *testing.T) {
+	intOrStr := func(i int) *intstr.IntOrString {
+		v := intstr.FromInt32(int32(i))
+		return &v
+	}
+	tests := []struct {
+		name string
+		kind model.WorkloadKind
+		cfg  RolloutConfig
+		// interfere is called after each scale up, to simulate other changes to the application
+		interfere func(t *testing.T, ctx model.Context, a *Application, step int)
+		// want is the number of replicas once complete. Defaults to the initial count.
+		want int
+	}{
+		{name: "pods", kind: model.PodKind},
+		{name: "pods surge only", kind: model.PodKind, cfg: RolloutConfig{MaxSurge: intOrStr(1), MaxUnavailable: intOrStr(0)}},
+		{name: "pods unavailable only", kind: model.PodKind, cfg: RolloutConfig{MaxSurge: intOrStr(0), MaxUnavailable: intOrStr(2)}},
+		{name: "endpoints", kind: model.EndpointSliceKind},
+		{name: "endpoints surge only", kind: model.EndpointSliceKind, cfg: RolloutConfig{MaxSurge: intOrStr(1), MaxUnavailable: intOrStr(0)}},
+		{
+			name: "pods scaled down mid rollout",
+			kind: model.PodKind,
+			want: 5,
+			cfg:  RolloutConfig{MaxSurge: intOrStr(1), MaxUnavailable: intOrStr(0)},
+			interfere: func(t *testing.T, ctx model.Context, a *Application, step int) {
+				if step == 1 {
+					// Removes random pods, old and new
+					if err := a.ScaleTo(ctx, a.Replicas()-3); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+		},
+		{
+			name: "pods refreshed mid rollout",
+			kind: model.PodKind,
+			interfere: func(t *testing.T, ctx model.Context, a *Application, step int) {
+				if _, err := a.Refresh(ctx); err != nil {
+					t.Fatal(err)
+				}
+			},
+		},
+		{
+			name: "endpoints scaled down mid rollout",
+			kind: model.EndpointSliceKind,
+			want: 5,
+			cfg:  RolloutConfig{MaxSurge: intOrStr(1), MaxUnavailable: intOrStr(0)},
+			interfere: func(t *testing.T, ctx model.Context, a *Application, step int) {
+				if step == 1 {
+					if err := a.ScaleTo(ctx, a.Replicas()-3); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := testContext()
+			const desired = 8
+			a := testApplication(t, ctx, tt.kind, desired)
+			old := map[any]bool{}
+			for _, m := range members(a) {
+				old[m] = true
+			}
+			r, err := a.StartRollout(tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for step := 0; !r.Done(); step++ {
+				if step > 50 {
+					t.Fatalf("rollout did not complete, %d replicas", a.Replicas())
+				}
+				created, err := r.ScaleUp(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if a.Replicas() > desired+r.surge {
+					t.Fatalf("step %d: %d replicas exceeds surge", step, a.Replicas())
+				}
+				if tt.interfere != nil {
+					tt.interfere(t, ctx, a, step)
+				}
+				removed, err := r.ScaleDown(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if created == 0 && removed == 0 && !r.Done() && tt.interfere == nil {
+					t.Fatalf("step %d: no progress", step)
+				}
+			}
+			for _, m := range members(a) {
+				if old[m] {
+					t.Fatalf("old replica %v was not replaced", m)
+				}
+			}
+			want := tt.want
+			if want == 0 {
+				want = desired
+			}
+			if a.Replicas() != want {
+				t.Fatalf("got %d replicas, want %d", a.Replicas(), want)
+			}
+			if a.endpoints != nil {
+				for _, ep := range a.endpoints.endpoints {
+					if !ep.ready {
+						t.Fatalf("endpoint %v is not ready", ep.ip)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRolloutSurgeLimit(t *testing.T) {
+	ctx := testContext()
+	a := testApplication(t, ctx, model.PodKind, 4)
+	zero := intstr.FromInt32(0)
+	one := intstr.FromInt32(1)
+	r, err := a.StartRollout(RolloutConfig{MaxSurge: &one, MaxUnavailable: &zero})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created, _ := r.ScaleUp(ctx); created != 1 {
+		t.Fatalf("got %d created, want 1", created)
+	}
+	// Until the old pod is removed, the surge limit blocks more pods
+	if created, _ := r.ScaleUp(ctx); created != 0 {
+		t.Fatalf("got %d created, want 0", created)
+	}
+	// Removing all old pods elsewhere completes the rollout, with nothing left to remove
+	a.pods = slices.DeleteFunc(a.pods, func(p *Pod) bool { return slices.Contains(r.old, p) })
+	if removed, err := r.ScaleDown(ctx); err != nil || removed != 0 {
+		t.Fatalf("got %d removed, %v", removed, err)
+	}
+	if !r.Done() {
+		t.Fatal("expected rollout to be done once no old pods remain")
+	}
+}
+
+func TestRolloutDeploymentStrategy(t *testing.T) {
+	ctx := testContext()
+	w := NewWorkload(WorkloadSpec{App: "app", Namespace: "ns", Kind: model.DeploymentKind, Replicas: 3})
+	a := &Application{Spec: &ApplicationSpec{App: "app"}, workload: w}
+	two := intstr.FromInt32(2)
+	r, err := a.StartRollout(RolloutConfig{MaxSurge: &two})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.ScaleUp(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if !r.Done() {
+		t.Fatal("expected the controller to perform the rollout")
+	}
+	got := w.strategy
+	if got == nil || got.MaxSurge.String() != "2" || got.MaxUnavailable.String() != "25%" {
+		t.Fatalf("got strategy %+v", got)
+	}
+}
@@ -11,11 +11,42 @@ import (
 	"github.com/howardjohn/pilot-load/pkg/simulation/model"
 )
 
+// ServiceConfig configures a service for an application.
+type ServiceConfig struct {
+	// Name is appended to the application name to form the service name. If unset, the application name is used.
+	Name string `json:"name,omitempty"`
+	// Ports for the service. Defaults to http/80 and https/443.
+	Ports []ServicePort `json:"ports,omitempty"`
+	// Headless, if set, creates a headless service.
+	Headless bool `json:"headless,omitempty"`
+	// ExternalName, if set, creates an ExternalName service pointing to this host.
+	ExternalName string `json:"externalName,omitempty"`
+}
+
+type ServicePort struct {
+	Name string `json:"name,omitempty"`
+	Port int32  `json:"port"`
+	// TargetPort defaults to Port.
+	TargetPort  int32  `json:"targetPort,omitempty"`
+	AppProtocol string `json:"appProtocol,omitempty"`
+	// Protocol is TCP (default), UDP, or SCTP.
+	Protocol string `json:"protocol,omitempty"`
+}
+
+var defaultPorts = []ServicePort{
+	{Name: "http", Port: 80},
+	{Name: "https", Port: 443},
+}
+
 type ServiceSpec struct {
 	App       string
 	Namespace string
 	Waypoint  bool
 	Labels    map[string]string
+	// Selectorless, if set, creates a service without a selector. Endpoints must be written directly.
+	Selectorless bool
+	// Config for the service. If unset, a service named after the application with default ports is created.
+	Config ServiceConfig
 }
 
 type Service struct {
@@ -36,19 +67,46 @@ func (s *Service) Cleanup(ctx model.Context) error {
 	return kube.Delete(ctx.Client, s.getService())
 }
 
+// Name returns the name of the service.
+func (s *Service) Name() string {
+	if s.Spec.Config.Name == "" {
+		return s.Spec.App
+	}
+	return s.Spec.App + "-" + s.Spec.Config.Name
+}
+
+// Ports returns the ports of the service.
+func (s *Service) Ports() []v1.ServicePort {
+	return s.getService().Spec.Ports
+}
+
+// HasEndpoints returns true if the service selects endpoints. ExternalName services do not.
+func (s *Service) HasEndpoints() bool {
+	return s.Spec.Config.ExternalName == ""
+}
+
 func (s *Service) getService() *v1.Service {
 	p := s.Spec
-	ports := []v1.ServicePort{
-		{
-			Name:       "http",
-			Port:       80,
-			TargetPort: intstr.FromInt(80),
-		},
-		{
-			Name:       "https",
-			Port:       443,
-			TargetPort: intstr.FromInt(443),
-		},
+	cfgPorts := p.Config.Ports
+	if len(cfgPorts) == 0 {
+		cfgPorts = defaultPorts
+	}
+	ports := make([]v1.ServicePort, 0, len(cfgPorts))
+	for _, port := range cfgPorts {
+		target := port.TargetPort
+		if target == 0 {
+			target = port.Port
+		}
+		sp := v1.ServicePort{
+			Name:       port.Name,
+			Port:       port.Port,
+			TargetPort: intstr.FromInt32(target),
+			Protocol:   v1.Protocol(port.Protocol),
+		}
+		if port.AppProtocol != "" {
+			sp.AppProtocol = ptr.Of(port.AppProtocol)
+		}
+		ports = append(ports, sp)
 	}
 	lbls := s.Spec.Labels
 	if s.Spec.Waypoint {
@@ -68,18 +126,27 @@ func (s *Service) getService() *v1.Service {
 	}
 	svc := &v1.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      p.App,
+			Name:      s.Name(),
 			Namespace: p.Namespace,
 			Labels:    lbls,
 		},
 		Spec: v1.ServiceSpec{
-			// TODO port customization
 			Ports: ports,
 			Type:  "ClusterIP",
 		},
 	}
-	svc.Spec.Selector = map[string]string{
-		"app": p.App,
+	if p.Config.Headless {
+		svc.Spec.ClusterIP = v1.ClusterIPNone
+	}
+	if p.Config.ExternalName != "" {
+		svc.Spec.Type = v1.ServiceTypeExternalName
+		svc.Spec.ExternalName = p.Config.ExternalName
+		return svc
+	}
+	if !p.Selectorless {
+		svc.Spec.Selector = map[string]string{
+			"app": p.App,
+		}
 	}
 	return svc
 }
@@ -0,0 +1,258 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"istio.io/istio/pkg/kube/controllers"
+	"istio.io/istio/pkg/kube/kclient"
+	"istio.io/istio/pkg/kube/kubetypes"
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/ptr"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/howardjohn/pilot-load/pkg/kube"
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+	"github.com/howardjohn/pilot-load/pkg/simulation/xds"
+)
+
+type WorkloadSpec struct {
+	App            string
+	Namespace      string
+	ServiceAccount string
+	Cluster        string
+	Network        string
+	Kind           model.WorkloadKind
+	Replicas       int
+	AppType        model.AppType
+}
+
+// Workload creates pods through a Kubernetes controller (Deployment, StatefulSet, or DaemonSet), rather than directly.
+// The pods are marked as running by the fake kubelet; once they get an IP an XDS connection is opened for each.
+type Workload struct {
+	Spec *WorkloadSpec
+
+	restartedAt string
+	// strategy is the rolling update strategy of a Deployment. If unset, the Kubernetes defaults are used.
+	strategy *appsv1.RollingUpdateDeployment
+
+	mu      sync.Mutex
+	proxies map[string]*xds.Simulation
+	stop    chan struct{}
+}
+
+var (
+	_ model.Simulation            = &Workload{}
+	_ model.ScalableSimulation    = &Workload{}
+	_ model.RefreshableSimulation = &Workload{}
+)
+
+func NewWorkload(s WorkloadSpec) *Workload {
+	return &Workload{Spec: &s, proxies: map[string]*xds.Simulation{}}
+}
+
+func (w *Workload) Run(ctx model.Context) error {
+	if err := w.apply(ctx); err != nil {
+		return err
+	}
+	if w.Spec.AppType.HasProxy() {
+		w.stop = make(chan struct{})
+		go w.watchPods(ctx)
+	}
+	return nil
+}
+
+func (w *Workload) Cleanup(ctx model.Context) error {
+	if w.stop != nil {
+		close(w.stop)
+	}
+	w.mu.Lock()
+	for name, p := range w.proxies {
+		if err := p.Cleanup(ctx); err != nil {
+			log.Warnf("failed to stop proxy %v: %v", name, err)
+		}
+	}
+	w.proxies = map[string]*xds.Simulation{}
+	w.mu.Unlock()
+	return kube.Delete(ctx.Client, w.getObject())
+}
+
+func (w *Workload) Refresh(ctx model.Context) (string, error) {
+	// Trigger a rollout, like `kubectl rollout restart`
+	w.restartedAt = time.Now().Format(time.RFC3339Nano)
+	if err := w.apply(ctx); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s (%s)", w.Spec.Namespace, w.Spec.App, w.Spec.Kind), nil
+}
+
+// SetRollingUpdate sets the limits used by a Deployment for later rollouts. Other kinds ignore them.
+func (w *Workload) SetRollingUpdate(maxSurge, maxUnavailable *intstr.IntOrString) {
+	w.strategy = &appsv1.RollingUpdateDeployment{MaxSurge: maxSurge, MaxUnavailable: maxUnavailable}
+}
+
+func (w *Workload) Scale(ctx model.Context, delta int) error {
+	return w.ScaleTo(ctx, w.Spec.Replicas+delta)
+}
+
+func (w *Workload) ScaleTo(ctx model.Context, n int) error {
+	if w.Spec.Kind == model.DaemonSetKind {
+		return fmt.Errorf("cannot scale DaemonSet %v", w.Spec.App)
+	}
+	log.Infof("%v: scaling %s from %d -> %d", w.Spec.App, w.Spec.Kind, w.Spec.Replicas, n)
+	w.Spec.Replicas = max(n, 0)
+	return w.apply(ctx)
+}
+
+func (w *Workload) apply(ctx model.Context) error {
+	obj := w.getObject()
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return kube.ApplyRealSSA(ctx.Client, o)
+	case *appsv1.StatefulSet:
+		return kube.ApplyRealSSA(ctx.Client, o)
+	case *appsv1.DaemonSet:
+		return kube.ApplyRealSSA(ctx.Client, o)
+	default:
+		return fmt.Errorf("unknown workload kind %v", w.Spec.Kind)
+	}
+}
+
+func (w *Workload) watchPods(ctx model.Context) {
+	pods := kclient.NewFiltered[*v1.Pod](ctx.Client, kubetypes.Filter{
+		Namespace:     w.Spec.Namespace,
+		LabelSelector: "app=" + w.Spec.App,
+	})
+	pods.AddEventHandler(controllers.EventHandler[*v1.Pod]{
+		AddFunc: func(p *v1.Pod) {
+			w.reconcilePod(ctx, p)
+		},
+		UpdateFunc: func(_, p *v1.Pod) {
+			w.reconcilePod(ctx, p)
+		},
+		DeleteFunc: func(p *v1.Pod) {
+			w.stopProxy(ctx, p.Name)
+		},
+	})
+	pods.Start(w.stop)
+}
+
+func (w *Workload) reconcilePod(ctx model.Context, p *v1.Pod) {
+	if p.DeletionTimestamp != nil {
+		w.stopProxy(ctx, p.Name)
+		return
+	}
+	if p.Status.PodIP == "" {
+		// Not yet running
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, f := w.proxies[p.Name]; f {
+		return
+	}
+	if w.stop == nil || isClosed(w.stop) {
+		return
+	}
+	proxy := &xds.Simulation{
+		Labels:         p.Labels,
+		Namespace:      p.Namespace,
+		ServiceAccount: p.Spec.ServiceAccountName,
+		Name:           p.Name,
+		IP:             p.Status.PodIP,
+		AppType:        w.Spec.AppType,
+		Cluster:        w.Spec.Cluster,
+		Network:        w.Spec.Network,
+		GrpcOpts:       ctx.Args.Auth.GrpcOptions(w.Spec.ServiceAccount, w.Spec.Namespace),
+		Delta:          ctx.Args.DeltaXDS,
+	}
+	if err := proxy.Run(ctx); err != nil {
+		log.Warnf("failed to start proxy for %v/%v: %v", p.Namespace, p.Name, err)
+		return
+	}
+	w.proxies[p.Name] = proxy
+}
+
+func (w *Workload) stopProxy(ctx model.Context, name string) {
+	w.mu.Lock()
+	proxy, f := w.proxies[name]
+	delete(w.proxies, name)
+	w.mu.Unlock()
+	if !f {
+		return
+	}
+	if err := proxy.Cleanup(ctx); err != nil {
+		log.Warnf("failed to stop proxy %v: %v", name, err)
+	}
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
+
+func (w *Workload) getObject() controllers.Object {
+	s := w.Spec
+	tmpl := podTemplate(s.App, s.ServiceAccount, s.AppType)
+	if w.restartedAt != "" {
+		tmpl.Annotations["pilot-load.istio.io/restartedAt"] = w.restartedAt
+	}
+	meta := metav1.ObjectMeta{
+		Name:      s.App,
+		Namespace: s.Namespace,
+		Labels: map[string]string{
+			"app":   s.App,
+			"owner": "pilot-load",
+		},
+	}
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": s.App}}
+	switch s.Kind {
+	case model.StatefulSetKind:
+		return &appsv1.StatefulSet{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"},
+			ObjectMeta: meta,
+			Spec: appsv1.StatefulSetSpec{
+				Replicas:    ptr.Of(int32(s.Replicas)),
+				Selector:    selector,
+				Template:    tmpl,
+				ServiceName: s.App,
+				// Pods have no readiness gating, so there is no benefit to ordered startup
+				PodManagementPolicy: appsv1.ParallelPodManagement,
+			},
+		}
+	case model.DaemonSetKind:
+		return &appsv1.DaemonSet{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "DaemonSet"},
+			ObjectMeta: meta,
+			Spec: appsv1.DaemonSetSpec{
+				Selector: selector,
+				Template: tmpl,
+			},
+		}
+	default:
+		d := &appsv1.Deployment{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			ObjectMeta: meta,
+			Spec: appsv1.DeploymentSpec{
+				Replicas: ptr.Of(int32(s.Replicas)),
+				Selector: selector,
+				Template: tmpl,
+			},
+		}
+		if w.strategy != nil {
+			d.Spec.Strategy = appsv1.DeploymentStrategy{
+				Type:          appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: w.strategy,
+			}
+		}
+		return d
+	}
+}
@@ -15,13 +15,16 @@ import (
 
 // Template inputs
 const (
-	RandNumber = "RandNumber"
-	Rand       = "Rand"
-	Namespace  = "Namespace"
-	Name       = "Name"
+	RandNumber     = "RandNumber"
+	Rand           = "Rand"
+	Namespace      = "Namespace"
+	Name           = "Name"
+	ServiceAccount = "ServiceAccount"
 )
 
 type TemplatedSpec struct {
+	// Name of the template, used to select configs
+	Name     string
 	Template *template.Template
 	Config   map[string]any
 	Refresh  *bool
@@ -54,6 +57,10 @@ func setupConfig(s *TemplatedSpec) {
 	}
 }
 
+func (v *Templated) TemplateName() string {
+	return v.Spec.Name
+}
+
 func (v *Templated) IsRefreshable() bool {
 	return v.Refreshable
 }
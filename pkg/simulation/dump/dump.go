@@ -91,6 +91,7 @@ func (i *DumpSimulation) Run(ctx model.Context) error {
 		IP:             ip,
 		Context:        ctx,
 		GrpcOpts:       ctx.Args.Auth.GrpcOptions(pod.Spec.ServiceAccountName, pod.Namespace),
+		Delta:          ctx.Args.DeltaXDS,
 		StoreResponses: true,
 	})
 	if err != nil {
@@ -12,6 +12,7 @@ import (
 	"golang.org/x/sync/errgroup"
 	"istio.io/istio/pkg/log"
 
+	"github.com/howardjohn/pilot-load/adsc"
 	"github.com/howardjohn/pilot-load/pkg/kube"
 	"github.com/howardjohn/pilot-load/pkg/simulation/security"
 	"github.com/howardjohn/pilot-load/pkg/simulation/util"
@@ -105,6 +106,24 @@ const (
 	VMType       AppType = "vm"
 )
 
+// WorkloadKind determines how pods for an application are created.
+type WorkloadKind string
+
+const (
+	// PodKind creates pods directly, which is the fastest option.
+	PodKind         WorkloadKind = "Pod"
+	DeploymentKind  WorkloadKind = "Deployment"
+	StatefulSetKind WorkloadKind = "StatefulSet"
+	DaemonSetKind   WorkloadKind = "DaemonSet"
+	// EndpointSliceKind creates no pods at all, only EndpointSlices for the service.
+	EndpointSliceKind WorkloadKind = "EndpointSlice"
+)
+
+// IsController returns true if pods are created by a Kubernetes controller, rather than directly.
+func (k WorkloadKind) IsController() bool {
+	return k == DeploymentKind || k == StatefulSetKind || k == DaemonSetKind
+}
+
 type ConfigTemplate struct {
 	Name    string         `json:"name,omitempty"`
 	Config  map[string]any `json:"config,omitempty"`
@@ -163,6 +182,7 @@ type AdscConfig struct {
 	Count     int
 	Delay     time.Duration
 	Namespace string
+	Cluster   string
 }
 
 type Selector string
@@ -202,6 +222,18 @@ type Args struct {
 	Metadata      map[string]string
 	DeltaXDS      bool
 	DumpConfig    DumpConfig
+	KubeQPS       int
+	Shard         Shard
+	// Nack configures XDS clients to reject some responses
+	Nack adsc.NackConfig
+	// ProcessingDelay configures XDS clients to process responses slowly
+	ProcessingDelay adsc.ProcessingDelay
+	// Validate enables validation of the config XDS clients receive
+	Validate bool
+	// OnDemand configures sidecars to load clusters on demand
+	OnDemand adsc.OnDemandConfig
+	// RecordDir, if set, is a directory to record all XDS responses to
+	RecordDir string
 }
 
 type Context struct {
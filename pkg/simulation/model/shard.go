@@ -0,0 +1,71 @@
+package model
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// Shard identifies the portion of a simulation owned by this process, when multiple processes cooperate
+// to generate load. The zero value owns everything.
+type Shard struct {
+	// Index of this shard, from 0 to Count-1.
+	Index int
+	// Count is the total number of shards.
+	Count int
+}
+
+// ParseShard parses a shard in the form "i/n".
+func ParseShard(s string) (Shard, error) {
+	if s == "" {
+		return Shard{}, nil
+	}
+	is, ns, ok := strings.Cut(s, "/")
+	if !ok {
+		return Shard{}, fmt.Errorf("invalid shard %q, expected i/n", s)
+	}
+	i, err := strconv.Atoi(is)
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard index %q: %v", is, err)
+	}
+	n, err := strconv.Atoi(ns)
+	if err != nil {
+		return Shard{}, fmt.Errorf("invalid shard count %q: %v", ns, err)
+	}
+	if n < 1 || i < 0 || i >= n {
+		return Shard{}, fmt.Errorf("invalid shard %q, expected 0 <= i < n", s)
+	}
+	return Shard{Index: i, Count: n}, nil
+}
+
+// IsSharded returns true if work is split across multiple processes.
+func (s Shard) IsSharded() bool {
+	return s.Count > 1
+}
+
+// Owns returns true if the i'th item belongs to this shard.
+func (s Shard) Owns(i int) bool {
+	if !s.IsSharded() {
+		return true
+	}
+	return i%s.Count == s.Index
+}
+
+// OwnsKey returns true if the item identified by key belongs to this shard. This is based on a stable hash,
+// so all processes agree on ownership without coordination.
+func (s Shard) OwnsKey(key string) bool {
+	if !s.IsSharded() {
+		return true
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32()%uint32(s.Count)) == s.Index
+}
+
+func (s Shard) String() string {
+	if !s.IsSharded() {
+		return "0/1"
+	}
+	return fmt.Sprintf("%d/%d", s.Index, s.Count)
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"testing"
+)
+
+func TestParseShard(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Shard
+		wantErr bool
+	}{
+		{in: "", want: Shard{}},
+		{in: "0/1", want: Shard{Index: 0, Count: 1}},
+		{in: "2/3", want: Shard{Index: 2, Count: 3}},
+		{in: "3/3", wantErr: true},
+		{in: "-1/3", wantErr: true},
+		{in: "0/0", wantErr: true},
+		{in: "1/0", wantErr: true},
+		{in: "1", wantErr: true},
+		{in: "a/2", wantErr: true},
+		{in: "1/b", wantErr: true},
+		{in: "1/2/3", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseShard(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShardOwns(t *testing.T) {
+	tests := []struct {
+		name  string
+		shard Shard
+		owned []int
+	}{
+		{name: "zero value", shard: Shard{}, owned: []int{0, 1, 2, 3, 4, 5}},
+		{name: "single", shard: Shard{Index: 0, Count: 1}, owned: []int{0, 1, 2, 3, 4, 5}},
+		{name: "first of two", shard: Shard{Index: 0, Count: 2}, owned: []int{0, 2, 4}},
+		{name: "second of two", shard: Shard{Index: 1, Count: 2}, owned: []int{1, 3, 5}},
+		{name: "last of three", shard: Shard{Index: 2, Count: 3}, owned: []int{2, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for i := range 6 {
+				if tt.shard.Owns(i) {
+					got = append(got, i)
+				}
+			}
+			if len(got) != len(tt.owned) {
+				t.Fatalf("got %v, want %v", got, tt.owned)
+			}
+			for i := range got {
+				if got[i] != tt.owned[i] {
+					t.Fatalf("got %v, want %v", got, tt.owned)
+				}
+			}
+		})
+	}
+}
+
+func TestShardOwnsKey(t *testing.T) {
+	keys := []string{"a", "b", "c", "ns/pod-1", "ns/pod-2", "adsc-10.0.0.10", ""}
+	for _, count := range []int{1, 2, 3, 7} {
+		for _, k := range keys {
+			owners := 0
+			for i := range count {
+				if (Shard{Index: i, Count: count}).OwnsKey(k) {
+					owners++
+				}
+			}
+			if owners != 1 {
+				t.Fatalf("key %q with %d shards has %d owners", k, count, owners)
+			}
+		}
+	}
+	for _, k := range keys {
+		if !(Shard{}).OwnsKey(k) {
+			t.Fatalf("unsharded should own %q", k)
+		}
+	}
+}
+
+func TestShardString(t *testing.T) {
+	if got := (Shard{}).String(); got != "0/1" {
+		t.Fatalf("got %v", got)
+	}
+	if got := (Shard{Index: 1, Count: 3}).String(); got != "1/3" {
+		t.Fatalf("got %v", got)
+	}
+}
@@ -20,6 +20,16 @@ import (
 type AuthOptions struct {
 	Type   AuthType
 	Client *kube.Client
+	// ClusterID is the cluster the client's credentials belong to. Istiod uses this to select the cluster to
+	// authenticate tokens against. Defaults to "Kubernetes".
+	ClusterID string
+}
+
+func (a *AuthOptions) clusterID() string {
+	if a.ClusterID == "" {
+		return "Kubernetes"
+	}
+	return a.ClusterID
 }
 
 type AuthType string
@@ -75,7 +85,7 @@ func (a *AuthOptions) Certificate(fetchRoot func() (string, error), addr, servic
 		Csr:              string(csrPEM),
 		ValidityDuration: int64((time.Hour * 24 * 7).Seconds()),
 	}
-	rctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("Authorization", "Bearer "+token, "ClusterID", "Kubernetes"))
+	rctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("Authorization", "Bearer "+token, "ClusterID", a.clusterID()))
 	resp, err := client.CreateCertificate(rctx, req)
 	if err != nil {
 		return Cert{}, fmt.Errorf("send CSR: %v", err)
@@ -108,6 +118,7 @@ func (a *AuthOptions) GrpcOptions(serviceAccount, namespace string) []grpc.DialO
 			}
 			return map[string]string{
 				"authorization": "Bearer " + token,
+				"clusterid":     a.clusterID(),
 			}, nil
 		}
 		return []grpc.DialOption{insecureTls, grpc.WithPerRPCCredentials(grpcCredentials{fetch})}
@@ -119,6 +130,7 @@ func (a *AuthOptions) GrpcOptions(serviceAccount, namespace string) []grpc.DialO
 			}
 			return map[string]string{
 				"authorization": "Bearer " + token,
+				"clusterid":     a.clusterID(),
 			}, nil
 		}
 		return []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithPerRPCCredentials(grpcCredentials{fetch})}
@@ -36,21 +36,34 @@ func Adsc(a model.Args) error {
 	}
 	opts := a.Auth.GrpcOptions("default", "default")
 
-	for i := 0; i < count; i++ {
+	for _, ip := range adscIPs(a.Shard, count) {
 		sims = append(sims, &xds.Simulation{
 			Namespace: a.AdsConfig.Namespace,
 			Name:      "adsc",
-			IP:        util.GetIP(),
-			// TODO: multicluster
-			Cluster:  "Kubernetes",
-			GrpcOpts: opts,
-			Delta:    a.DeltaXDS,
-			Labels:   a.Metadata,
+			IP:        ip,
+			Cluster:   a.AdsConfig.Cluster,
+			GrpcOpts:  opts,
+			Delta:     a.DeltaXDS,
+			Labels:    a.Metadata,
 		})
 	}
+	if a.Shard.IsSharded() {
+		log.Infof("shard %v owns %d of %d connections", a.Shard, len(sims), count)
+	}
 	return ExecuteSimulations(a, model.AggregateSimulation{Simulations: sims, Delay: a.AdsConfig.Delay})
 }
 
+// adscIPs returns the IPs of the proxies this shard runs, out of count across all shards.
+func adscIPs(shard model.Shard, count int) []string {
+	var ips []string
+	for i := range count {
+		if shard.Owns(i) {
+			ips = append(ips, util.IndexIP(i))
+		}
+	}
+	return ips
+}
+
 type Running struct {
 	ch chan error
 }
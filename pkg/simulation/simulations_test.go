@@ -0,0 +1,32 @@
+package simulation
+
+import (
+	"testing"
+
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+)
+
+func TestAdscIPsPartitioned(t *testing.T) {
+	for _, count := range []int{1, 7, 100} {
+		for _, shards := range []int{1, 2, 3, 8} {
+			seen := map[string]int{}
+			for i := range shards {
+				for _, ip := range adscIPs(model.Shard{Index: i, Count: shards}, count) {
+					if prev, f := seen[ip]; f {
+						t.Fatalf("count %d, %d shards: ip %v owned by shard %d and %d", count, shards, ip, prev, i)
+					}
+					seen[ip] = i
+				}
+			}
+			if len(seen) != count {
+				t.Fatalf("count %d, %d shards: got %d distinct ips", count, shards, len(seen))
+			}
+			// Sharding must not change which proxies exist
+			for _, ip := range adscIPs(model.Shard{}, count) {
+				if _, f := seen[ip]; !f {
+					t.Fatalf("count %d, %d shards: ip %v missing", count, shards, ip)
+				}
+			}
+		}
+	}
+}
@@ -63,20 +63,42 @@ func GenUID() string {
 
 var (
 	ipMutex sync.Mutex
-	nextIp  = net.ParseIP("10.0.0.10")
+	ipBase  = ipToInt(net.ParseIP("10.0.0.10"))
+	ipNext  uint32
+	// ipShard and ipShards partition the IPs across processes, so each hands out distinct IPs
+	ipShard  uint32
+	ipShards uint32 = 1
 )
 
+// SetIPShard partitions IPs returned by GetIP, so processes with distinct shard indexes never overlap.
+func SetIPShard(index, count int) {
+	ipMutex.Lock()
+	defer ipMutex.Unlock()
+	ipShard = uint32(max(index, 0))
+	ipShards = uint32(max(count, 1))
+}
+
 func GetIP() string {
 	ipMutex.Lock()
 	defer ipMutex.Unlock()
-	i := nextIp.To4()
-	ret := i.String()
-	v := uint(i[0])<<24 + uint(i[1])<<16 + uint(i[2])<<8 + uint(i[3])
-	v += 1
-	v3 := byte(v & 0xFF)
-	v2 := byte((v >> 8) & 0xFF)
-	v1 := byte((v >> 16) & 0xFF)
-	v0 := byte((v >> 24) & 0xFF)
-	nextIp = net.IPv4(v0, v1, v2, v3)
+	ret := shardIP(ipNext, ipShard, ipShards)
+	ipNext++
 	return ret
 }
+
+// shardIP returns the n'th IP of the shard. IPs are interleaved between shards.
+func shardIP(n, shard, shards uint32) string {
+	return IndexIP(int(n*shards + shard))
+}
+
+// IndexIP returns the i'th IP of the full sequence, across all shards. The IPs GetIP returns for shard s of n are
+// those with an index i where i%n == s.
+func IndexIP(i int) string {
+	v := ipBase + uint32(i)
+	return net.IPv4(byte(v>>24), byte(v>>16), byte(v>>8), byte(v)).String()
+}
+
+func ipToInt(ip net.IP) uint32 {
+	i := ip.To4()
+	return uint32(i[0])<<24 + uint32(i[1])<<16 + uint32(i[2])<<8 + uint32(i[3])
+}
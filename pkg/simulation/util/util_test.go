@@ -0,0 +1,54 @@
+package util
+
+import (
+	"testing"
+)
+
+func TestShardIPsDoNotOverlap(t *testing.T) {
+	const n = 100000
+	for _, shards := range []uint32{1, 2, 3} {
+		seen := map[string]uint32{}
+		for shard := range shards {
+			for i := range uint32(n) {
+				ip := shardIP(i, shard, shards)
+				if prev, f := seen[ip]; f {
+					t.Fatalf("%d shards: ip %v used by shard %d and %d", shards, ip, prev, shard)
+				}
+				seen[ip] = shard
+			}
+		}
+	}
+}
+
+func TestShardIP(t *testing.T) {
+	if got := shardIP(0, 0, 1); got != "10.0.0.10" {
+		t.Fatalf("got %v", got)
+	}
+	// Carries into the next octet
+	if got := shardIP(246, 0, 1); got != "10.0.1.0" {
+		t.Fatalf("got %v", got)
+	}
+	if got := shardIP(1, 1, 2); got != "10.0.0.13" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestGetIPSharded(t *testing.T) {
+	t.Cleanup(func() {
+		SetIPShard(0, 1)
+	})
+	got := map[string]bool{}
+	for shard := range 2 {
+		ipMutex.Lock()
+		ipNext = 0
+		ipMutex.Unlock()
+		SetIPShard(shard, 2)
+		for range 1000 {
+			ip := GetIP()
+			if got[ip] {
+				t.Fatalf("shard %d: duplicate ip %v", shard, ip)
+			}
+			got[ip] = true
+		}
+	}
+}
@@ -18,9 +18,13 @@ type Simulation struct {
 	IP             string
 	// Defaults to "Kubernetes"
 	Cluster string
+	// Network the proxy is in. If unset, no network is sent
+	Network string
 	AppType model.AppType
 
 	GrpcOpts []grpc.DialOption
+	// Ztunnel configures address subscriptions, for ztunnel nodes.
+	Ztunnel adsc.ZtunnelConfig
 
 	cancel context.CancelFunc
 	done   chan struct{}
@@ -50,6 +54,9 @@ func (x *Simulation) Run(ctx model.Context) error {
 	meta["NAMESPACE"] = x.Namespace
 	meta["SERVICE_ACCOUNT"] = x.ServiceAccount
 	meta["PROXY_CONFIG"] = map[string]string{}
+	if x.Network != "" {
+		meta["NETWORK"] = x.Network
+	}
 	for k, v := range x.Metadata {
 		meta[k] = v
 	}
@@ -58,15 +65,26 @@ func (x *Simulation) Run(ctx model.Context) error {
 		if nt == "gateway" {
 			nt = "router"
 		}
+		var onDemand adsc.OnDemandConfig
+		if nt == string(model.SidecarType) {
+			// Like Envoy, only sidecars load clusters on demand
+			onDemand = ctx.Args.OnDemand
+		}
 		adsc.Connect(ctx.Args.PilotAddress, &adsc.Config{
-			Namespace: x.Namespace,
-			Workload:  x.Name + "-" + x.IP,
-			Meta:      meta,
-			NodeType:  nt,
-			IP:        x.IP,
-			Context:   c,
-			GrpcOpts:  x.GrpcOpts,
-			Delta:     x.Delta,
+			Namespace:  x.Namespace,
+			Workload:   x.Name + "-" + x.IP,
+			Meta:       meta,
+			NodeType:   nt,
+			IP:         x.IP,
+			Context:    c,
+			GrpcOpts:   x.GrpcOpts,
+			Delta:      x.Delta,
+			Nack:       ctx.Args.Nack,
+			Delay:      ctx.Args.ProcessingDelay,
+			Validate:   ctx.Args.Validate,
+			OnDemand:   onDemand,
+			Ztunnel:    x.Ztunnel,
+			RecordFile: adsc.RecordingFile(ctx.Args.RecordDir, x.Namespace+"-"+x.Name+"-"+x.IP),
 		})
 		close(x.done)
 	}()
@@ -0,0 +1,115 @@
+// Package stats keeps lightweight, in-process counters and latency samples so a simulation can report
+// a summary of its run without scraping the Prometheus endpoint.
+package stats
+
+import (
+	"maps"
+	"math/rand"
+	"slices"
+	"sync"
+	"time"
+)
+
+// maxSamples bounds the memory used by a single Durations. Once exceeded, samples are kept via reservoir sampling.
+const maxSamples = 10000
+
+var (
+	// ApplyLatency records the time taken by each server side apply to the API server.
+	ApplyLatency = NewDurations()
+	// XDSConnect records the time taken to establish each XDS connection.
+	XDSConnect = NewDurations()
+	// XDSFirstPush records the time from starting a connection until the first response is received.
+	XDSFirstPush = NewDurations()
+
+	// Created counts objects created, by kind.
+	Created = NewCounters()
+	// Errors counts errors encountered, by category.
+	Errors = NewCounters()
+)
+
+type Durations struct {
+	mu      sync.Mutex
+	count   int
+	max     time.Duration
+	samples []time.Duration
+}
+
+func NewDurations() *Durations {
+	return &Durations{}
+}
+
+func (d *Durations) Record(v time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.count++
+	d.max = max(d.max, v)
+	if len(d.samples) < maxSamples {
+		d.samples = append(d.samples, v)
+		return
+	}
+	if i := rand.Intn(d.count); i < maxSamples {
+		d.samples[i] = v
+	}
+}
+
+type Percentiles struct {
+	Count int           `json:"count"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+func (d *Durations) Percentiles() Percentiles {
+	d.mu.Lock()
+	samples := slices.Clone(d.samples)
+	res := Percentiles{Count: d.count, Max: d.max}
+	d.mu.Unlock()
+	if len(samples) == 0 {
+		return res
+	}
+	slices.Sort(samples)
+	res.P50 = percentile(samples, 0.5)
+	res.P90 = percentile(samples, 0.9)
+	res.P99 = percentile(samples, 0.99)
+	return res
+}
+
+// percentile returns the nearest-rank percentile of sorted samples.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	idx := int(float64(len(sorted))*p+0.5) - 1
+	idx = max(0, min(idx, len(sorted)-1))
+	return sorted[idx]
+}
+
+type Counters struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func NewCounters() *Counters {
+	return &Counters{counts: map[string]int{}}
+}
+
+func (c *Counters) Inc(key string) {
+	c.Add(key, 1)
+}
+
+func (c *Counters) Add(key string, n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[key] += n
+}
+
+func (c *Counters) Get(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[key]
+}
+
+// Snapshot returns a copy of all counts.
+func (c *Counters) Snapshot() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return maps.Clone(c.counts)
+}
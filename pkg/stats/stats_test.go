@@ -0,0 +1,40 @@
+package stats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPercentiles(t *testing.T) {
+	d := NewDurations()
+	if got := d.Percentiles(); got != (Percentiles{}) {
+		t.Fatalf("expected empty percentiles, got %+v", got)
+	}
+	for i := 1; i <= 100; i++ {
+		d.Record(time.Duration(i) * time.Millisecond)
+	}
+	want := Percentiles{
+		Count: 100,
+		P50:   50 * time.Millisecond,
+		P90:   90 * time.Millisecond,
+		P99:   99 * time.Millisecond,
+		Max:   100 * time.Millisecond,
+	}
+	if got := d.Percentiles(); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDurationsBounded(t *testing.T) {
+	d := NewDurations()
+	for i := 0; i < maxSamples*3; i++ {
+		d.Record(time.Duration(i))
+	}
+	if len(d.samples) != maxSamples {
+		t.Fatalf("expected %d samples, got %d", maxSamples, len(d.samples))
+	}
+	got := d.Percentiles()
+	if got.Count != maxSamples*3 || got.Max != time.Duration(maxSamples*3-1) {
+		t.Fatalf("unexpected percentiles %+v", got)
+	}
+}
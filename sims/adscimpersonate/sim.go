@@ -81,6 +81,11 @@ func (i *Simulation) Run(ctx model.Context) error {
 			i.del(ctx, key)
 			return nil
 		}
+		if !ctx.Args.Shard.OwnsKey(key.String()) {
+			// Owned by another instance
+			i.del(ctx, key)
+			return nil
+		}
 		if pod.Status.PodIP == "" {
 			// Need a pod IP before we can watch
 			i.del(ctx, key)
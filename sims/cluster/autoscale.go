@@ -0,0 +1,227 @@
+package cluster
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"istio.io/istio/pkg/log"
+
+	"github.com/howardjohn/pilot-load/pkg/simulation/app"
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+	"github.com/howardjohn/pilot-load/pkg/stats"
+)
+
+type AutoscaleMode string
+
+const (
+	// AutoscaleSine follows a sinusoidal wave, like daily traffic, starting at the minimum.
+	AutoscaleSine AutoscaleMode = "sine"
+	// AutoscaleStep holds the minimum, bursting to the maximum at the end of each period.
+	AutoscaleStep AutoscaleMode = "step"
+	// AutoscaleReplay replays replica counts from a CSV file.
+	AutoscaleReplay AutoscaleMode = "replay"
+)
+
+// AutoscaleConfig models an autoscaler for an application, scaling its pods over time once the cluster is synced.
+type AutoscaleConfig struct {
+	Mode AutoscaleMode `json:"mode"`
+	Min  int           `json:"min,omitempty"`
+	Max  int           `json:"max,omitempty"`
+	// Interval between scaling decisions. Defaults to 10s.
+	Interval model.Duration `json:"interval,omitempty"`
+	// Period of the wave or bursts. Defaults to 24h.
+	Period model.Duration `json:"period,omitempty"`
+	// Burst is how long each step burst lasts. Defaults to a tenth of the period.
+	Burst model.Duration `json:"burst,omitempty"`
+	// File is a CSV of "seconds,replicas" rows for replay, where seconds is the time since the start.
+	File string `json:"file,omitempty"`
+	// Loop restarts the replay after the last row.
+	Loop bool `json:"loop,omitempty"`
+
+	samples []autoscaleSample
+}
+
+const (
+	defaultAutoscaleInterval = 10 * time.Second
+	defaultAutoscalePeriod   = 24 * time.Hour
+)
+
+type autoscaleSample struct {
+	at       time.Duration
+	replicas int
+}
+
+// Validate checks the config is well formed, applying defaults and loading the replay file.
+func (a *AutoscaleConfig) Validate() error {
+	if a == nil {
+		return nil
+	}
+	if a.Interval == 0 {
+		a.Interval = model.Duration(defaultAutoscaleInterval)
+	}
+	if a.Interval < 0 {
+		return fmt.Errorf("autoscale: interval must be positive, got %v", a.Interval)
+	}
+	if a.Period == 0 {
+		a.Period = model.Duration(defaultAutoscalePeriod)
+	}
+	if a.Burst == 0 {
+		a.Burst = a.Period / 10
+	}
+	switch a.Mode {
+	case AutoscaleSine, AutoscaleStep:
+		if a.Min < 0 || a.Max < a.Min {
+			return fmt.Errorf("autoscale: invalid range min=%d max=%d", a.Min, a.Max)
+		}
+		if a.Burst > a.Period {
+			return fmt.Errorf("autoscale: burst %v is longer than period %v", a.Burst, a.Period)
+		}
+	case AutoscaleReplay:
+		samples, err := readAutoscaleFile(a.File)
+		if err != nil {
+			return fmt.Errorf("autoscale: %v", err)
+		}
+		a.samples = samples
+	default:
+		return fmt.Errorf("autoscale: unknown mode %q", a.Mode)
+	}
+	return nil
+}
+
+func readAutoscaleFile(file string) ([]autoscaleSample, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = 2
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %v: %v", file, err)
+	}
+	var samples []autoscaleSample
+	for i, row := range rows {
+		at, err := strconv.ParseFloat(strings.TrimSpace(row[0]), 64)
+		if err != nil {
+			if i == 0 {
+				// Header
+				continue
+			}
+			return nil, fmt.Errorf("%v: row %d: invalid time %q", file, i+1, row[0])
+		}
+		replicas, err := strconv.Atoi(strings.TrimSpace(row[1]))
+		if err != nil || replicas < 0 {
+			return nil, fmt.Errorf("%v: row %d: invalid replicas %q", file, i+1, row[1])
+		}
+		s := autoscaleSample{at: time.Duration(at * float64(time.Second)), replicas: replicas}
+		if len(samples) > 0 && s.at < samples[len(samples)-1].at {
+			return nil, fmt.Errorf("%v: row %d: times must be increasing", file, i+1)
+		}
+		samples = append(samples, s)
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("%v: no rows", file)
+	}
+	return samples, nil
+}
+
+// Target returns the desired replicas at the time since the autoscaler started.
+func (a *AutoscaleConfig) Target(elapsed time.Duration) int {
+	period := time.Duration(a.Period)
+	if period <= 0 {
+		period = defaultAutoscalePeriod
+	}
+	switch a.Mode {
+	case AutoscaleSine:
+		phase := 2 * math.Pi * float64(elapsed%period) / float64(period)
+		return a.Min + int(math.Round(float64(a.Max-a.Min)*(1-math.Cos(phase))/2))
+	case AutoscaleStep:
+		if elapsed%period >= period-time.Duration(a.Burst) {
+			return a.Max
+		}
+		return a.Min
+	case AutoscaleReplay:
+		if a.Loop {
+			// The last row lasts for one interval before restarting
+			elapsed %= a.samples[len(a.samples)-1].at + time.Duration(a.Interval)
+		}
+		target := a.samples[0].replicas
+		for _, s := range a.samples {
+			if s.at > elapsed {
+				break
+			}
+			target = s.replicas
+		}
+		return target
+	}
+	return 0
+}
+
+// autoscaledApplication is an application with an autoscaler.
+type autoscaledApplication struct {
+	ns  *Namespace
+	app *app.Application
+	cfg *AutoscaleConfig
+}
+
+// autoscaledApplications returns all applications with an autoscaler.
+func (c *Cluster) autoscaledApplications() []autoscaledApplication {
+	var res []autoscaledApplication
+	for _, ns := range c.namespaces {
+		for i, a := range ns.deployments {
+			if cfg := ns.autoscale[i]; cfg != nil {
+				res = append(res, autoscaledApplication{ns: ns, app: a, cfg: cfg})
+			}
+		}
+	}
+	return res
+}
+
+// runAutoscaler scales the application to the autoscaler's target at each interval.
+func (s *ClusterScaler) runAutoscaler(ctx context.Context, a autoscaledApplication, do func(func())) {
+	if a.cfg.Mode == AutoscaleReplay && len(a.cfg.samples) == 0 {
+		// Samples are loaded by Validate
+		log.Errorf("autoscale: no samples to replay for %v", a.app.Spec.App)
+		return
+	}
+	start := time.Now()
+	interval := time.Duration(a.cfg.Interval)
+	if interval <= 0 {
+		// Validate applies the default, but the config may not have been validated
+		interval = defaultAutoscaleInterval
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		target := a.cfg.Target(time.Since(start))
+		do(func() {
+			if a.ns.deleted {
+				return
+			}
+			current := a.app.Replicas()
+			if current == target {
+				return
+			}
+			log.Infof("autoscale: scaling %v/%v from %d to %d", a.app.Spec.Namespace, a.app.Spec.App, current, target)
+			if err := a.app.ScaleTo(s.ctx, target); err != nil {
+				stats.Errors.Inc("autoscale")
+				log.Errorf("autoscale: failed to scale %v: %v", a.app.Spec.App, err)
+				return
+			}
+			s.refreshes.Inc("autoscale")
+		})
+	}
+}
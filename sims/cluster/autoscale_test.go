@@ -0,0 +1,157 @@
+package cluster
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+)
+
+func TestAutoscaleValidate(t *testing.T) {
+	hour := model.Duration(time.Hour)
+	tests := []struct {
+		name    string
+		cfg     AutoscaleConfig
+		wantErr bool
+	}{
+		{name: "sine", cfg: AutoscaleConfig{Mode: AutoscaleSine, Min: 1, Max: 5}},
+		{name: "step", cfg: AutoscaleConfig{Mode: AutoscaleStep, Min: 1, Max: 5, Period: hour, Burst: hour}},
+		{name: "negative interval", cfg: AutoscaleConfig{Mode: AutoscaleSine, Max: 1, Interval: -hour}, wantErr: true},
+		{name: "negative min", cfg: AutoscaleConfig{Mode: AutoscaleSine, Min: -1, Max: 1}, wantErr: true},
+		{name: "max below min", cfg: AutoscaleConfig{Mode: AutoscaleSine, Min: 2, Max: 1}, wantErr: true},
+		{name: "burst longer than period", cfg: AutoscaleConfig{Mode: AutoscaleStep, Max: 1, Period: hour, Burst: 2 * hour}, wantErr: true},
+		{name: "replay without file", cfg: AutoscaleConfig{Mode: AutoscaleReplay}, wantErr: true},
+		{name: "unknown mode", cfg: AutoscaleConfig{Mode: "other"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAutoscaleValidateDefaults(t *testing.T) {
+	cfg := AutoscaleConfig{Mode: AutoscaleStep, Max: 1}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(cfg.Interval) != defaultAutoscaleInterval {
+		t.Fatalf("got interval %v, want %v", cfg.Interval, defaultAutoscaleInterval)
+	}
+	if time.Duration(cfg.Period) != defaultAutoscalePeriod {
+		t.Fatalf("got period %v, want %v", cfg.Period, defaultAutoscalePeriod)
+	}
+	if cfg.Burst != cfg.Period/10 {
+		t.Fatalf("got burst %v, want a tenth of the period", cfg.Burst)
+	}
+}
+
+// writeReplay writes a replay file with the rows, returning its path.
+func writeReplay(t *testing.T, rows ...string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "replay.csv")
+	if err := os.WriteFile(path, []byte(strings.Join(rows, "\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAutoscaleTarget(t *testing.T) {
+	hour := model.Duration(time.Hour)
+	sine := AutoscaleConfig{Mode: AutoscaleSine, Min: 2, Max: 10, Period: hour}
+	step := AutoscaleConfig{Mode: AutoscaleStep, Min: 2, Max: 10, Period: hour, Burst: model.Duration(10 * time.Minute)}
+	replay := func(loop bool) AutoscaleConfig {
+		return AutoscaleConfig{
+			Mode:     AutoscaleReplay,
+			Interval: model.Duration(10 * time.Second),
+			File:     writeReplay(t, "seconds,replicas", "0,1", "30,4", "60,2"),
+			Loop:     loop,
+		}
+	}
+	tests := []struct {
+		name    string
+		cfg     AutoscaleConfig
+		elapsed time.Duration
+		want    int
+	}{
+		{name: "sine start", cfg: sine, elapsed: 0, want: 2},
+		{name: "sine quarter", cfg: sine, elapsed: 15 * time.Minute, want: 6},
+		{name: "sine peak", cfg: sine, elapsed: 30 * time.Minute, want: 10},
+		{name: "sine next period", cfg: sine, elapsed: time.Hour, want: 2},
+		{name: "step before burst", cfg: step, elapsed: 49 * time.Minute, want: 2},
+		{name: "step burst", cfg: step, elapsed: 50 * time.Minute, want: 10},
+		{name: "step next period", cfg: step, elapsed: 61 * time.Minute, want: 2},
+		{name: "replay start", cfg: replay(false), elapsed: 0, want: 1},
+		{name: "replay row", cfg: replay(false), elapsed: 45 * time.Second, want: 4},
+		{name: "replay holds last row", cfg: replay(false), elapsed: time.Hour, want: 2},
+		// The last row lasts one interval, so the replay restarts at 70s
+		{name: "replay loop last row", cfg: replay(true), elapsed: 65 * time.Second, want: 2},
+		{name: "replay loop restarts", cfg: replay(true), elapsed: 70 * time.Second, want: 1},
+		{name: "replay loop second row", cfg: replay(true), elapsed: 100 * time.Second, want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if got := cfg.Target(tt.elapsed); got != tt.want {
+				t.Fatalf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadAutoscaleFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    []string
+		want    int
+		wantErr bool
+	}{
+		{name: "header", rows: []string{"seconds,replicas", "0,1", "10,2"}, want: 2},
+		{name: "no header", rows: []string{"0,1", "1.5,2"}, want: 2},
+		{name: "comments", rows: []string{"# comment", "0,1"}, want: 1},
+		{name: "empty", rows: []string{"seconds,replicas"}, wantErr: true},
+		{name: "invalid time", rows: []string{"0,1", "x,2"}, wantErr: true},
+		{name: "negative replicas", rows: []string{"0,-1"}, wantErr: true},
+		{name: "decreasing times", rows: []string{"10,1", "5,2"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			samples, err := readAutoscaleFile(writeReplay(t, tt.rows...))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(samples) != tt.want {
+				t.Fatalf("got %d samples, want %d", len(samples), tt.want)
+			}
+		})
+	}
+}
+
+func TestAutoscaleDaemonSetRejected(t *testing.T) {
+	cfg := func(kind string) string {
+		return `
+namespaces:
+- name: ns
+  applications:
+  - name: app
+    workloadKind: ` + kind + `
+    autoscale:
+      mode: step
+      max: 2
+`
+	}
+	if _, err := ReadConfig(cfg("Deployment")); err != nil {
+		t.Fatalf("unexpected error for a Deployment: %v", err)
+	}
+	if _, err := ReadConfig(cfg("DaemonSet")); err == nil {
+		t.Fatal("expected autoscale of a DaemonSet to be rejected")
+	}
+}
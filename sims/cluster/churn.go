@@ -0,0 +1,191 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"istio.io/istio/pkg/log"
+
+	"github.com/howardjohn/pilot-load/pkg/simulation/app"
+	"github.com/howardjohn/pilot-load/pkg/simulation/config"
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+	"github.com/howardjohn/pilot-load/pkg/stats"
+)
+
+type ChurnKind string
+
+const (
+	ChurnWorkloads ChurnKind = "workloads"
+	ChurnConfig    ChurnKind = "config"
+	// ChurnRollout replaces all pods of an application in batches, like a Deployment rolling update.
+	ChurnRollout ChurnKind = "rollout"
+)
+
+// ChurnRule refreshes or rolls out matching workloads, or refreshes matching configs, at its own interval, alongside the global jitter.
+// This allows isolating the cost of a single type of churn.
+type ChurnRule struct {
+	// Name identifies the rule in logs and the summary. Defaults to the selector.
+	Name     string         `json:"name,omitempty"`
+	Interval model.Duration `json:"interval"`
+	// Kind is what to refresh. Defaults to workloads, or config if Template is set.
+	Kind             ChurnKind `json:"kind,omitempty"`
+	ScenarioSelector `json:",inline"`
+	// Type selects applications of the type.
+	Type model.AppType `json:"type,omitempty"`
+	// Template selects configs created from the named template.
+	Template string `json:"template,omitempty"`
+	// RolloutConfig configures the rolling update, for the rollout kind.
+	app.RolloutConfig `json:",inline"`
+}
+
+func (r ChurnRule) kind() ChurnKind {
+	if r.Kind != "" {
+		return r.Kind
+	}
+	if r.Template != "" {
+		return ChurnConfig
+	}
+	return ChurnWorkloads
+}
+
+func (r ChurnRule) String() string {
+	if r.Name != "" {
+		return r.Name
+	}
+	s := fmt.Sprintf("%s %v", r.kind(), r.ScenarioSelector)
+	if r.Type != "" {
+		s += " type=" + string(r.Type)
+	}
+	if r.Template != "" {
+		s += " template=" + r.Template
+	}
+	return s
+}
+
+// Validate checks the rule is well formed.
+func (r ChurnRule) Validate() error {
+	if r.Interval <= 0 {
+		return fmt.Errorf("churn rule %q: interval is required", r)
+	}
+	switch r.kind() {
+	case ChurnWorkloads, ChurnRollout:
+		if r.Template != "" {
+			return fmt.Errorf("churn rule %q: template requires kind %v", r, ChurnConfig)
+		}
+	case ChurnConfig:
+	default:
+		return fmt.Errorf("churn rule %q: unknown kind %q", r, r.Kind)
+	}
+	return nil
+}
+
+// selectChurnApplications returns the applications matching the rule.
+func (c *Cluster) selectChurnApplications(r ChurnRule) []*app.Application {
+	var res []*app.Application
+	for _, a := range c.selectApplications(r.ScenarioSelector) {
+		if r.Type == "" || r.Type == a.Spec.Type {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// selectChurnConfig returns the refreshable configs matching the rule.
+func (c *Cluster) selectChurnConfig(r ChurnRule) []model.RefreshableSimulation {
+	var cfgs []model.RefreshableSimulation
+	for _, a := range c.selectChurnApplications(r) {
+		for _, cfg := range a.GetConfigs() {
+			if !model.IsRefreshable(cfg) {
+				continue
+			}
+			if r.Template != "" {
+				if t, ok := cfg.(*config.Templated); !ok || t.TemplateName() != r.Template {
+					continue
+				}
+			}
+			cfgs = append(cfgs, cfg)
+		}
+	}
+	return cfgs
+}
+
+// runChurnRule refreshes the rule's targets at its interval.
+func (s *ClusterScaler) runChurnRule(ctx context.Context, r ChurnRule, do func(func())) {
+	t := makeTicker(time.Duration(r.Interval))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t:
+		}
+		var ok bool
+		switch r.kind() {
+		case ChurnWorkloads:
+			do(func() {
+				ok = s.refreshWorkload(s.Cluster.selectChurnApplications(r))
+			})
+		case ChurnConfig:
+			do(func() {
+				ok = s.refreshConfig(s.Cluster.selectChurnConfig(r))
+			})
+		case ChurnRollout:
+			var apps []*app.Application
+			do(func() {
+				apps = s.Cluster.selectChurnApplications(r)
+			})
+			ok = s.rollout(ctx, apps, r.RolloutConfig, do)
+		}
+		if ok {
+			s.refreshes.Inc("rule/" + r.String())
+		}
+	}
+}
+
+// rolloutBackoff is how long to wait before retrying a rollout step that made no progress.
+const rolloutBackoff = time.Second
+
+// rollout performs a rolling update of a random application, returning true if it completed. Each step is passed
+// to do, and readiness is waited for between them, so other changes are not blocked during the rollout.
+func (s *ClusterScaler) rollout(ctx context.Context, apps []*app.Application, cfg app.RolloutConfig, do func(func())) bool {
+	if len(apps) == 0 {
+		log.Warnf("no instances to roll out")
+		return false
+	}
+	a := apps[rand.Intn(len(apps))]
+	var r *app.Rollout
+	var err error
+	do(func() {
+		r, err = a.StartRollout(cfg)
+	})
+	for err == nil && ctx.Err() == nil && !r.Done() {
+		created, removed := 0, 0
+		do(func() {
+			created, err = r.ScaleUp(s.ctx)
+		})
+		if err != nil || ctx.Err() != nil || r.Done() {
+			break
+		}
+		if created > 0 && !sleepCtx(ctx, time.Duration(cfg.ReadyDelay)) {
+			break
+		}
+		do(func() {
+			removed, err = r.ScaleDown(s.ctx)
+		})
+		// Other changes may block progress for a while, such as by scaling the application, so back off
+		if err == nil && created == 0 && removed == 0 && !sleepCtx(ctx, rolloutBackoff) {
+			break
+		}
+	}
+	if err != nil {
+		stats.Errors.Inc("rollout")
+		log.Errorf("failed to roll out %v: %v", a.Spec.App, err)
+		return false
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	log.Infof("rolled out %s/%s", a.Spec.Namespace, a.Spec.App)
+	return true
+}
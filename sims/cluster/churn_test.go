@@ -0,0 +1,137 @@
+package cluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/howardjohn/pilot-load/pkg/simulation/config"
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+)
+
+func TestChurnRuleKind(t *testing.T) {
+	tests := []struct {
+		name string
+		rule ChurnRule
+		want ChurnKind
+	}{
+		{name: "default", rule: ChurnRule{}, want: ChurnWorkloads},
+		{name: "template", rule: ChurnRule{Template: "virtualservice"}, want: ChurnConfig},
+		{name: "explicit", rule: ChurnRule{Kind: ChurnRollout}, want: ChurnRollout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.kind(); got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChurnRuleValidate(t *testing.T) {
+	second := model.Duration(time.Second)
+	tests := []struct {
+		name    string
+		rule    ChurnRule
+		wantErr bool
+	}{
+		{name: "workloads", rule: ChurnRule{Interval: second}},
+		{name: "config", rule: ChurnRule{Interval: second, Template: "virtualservice"}},
+		{name: "rollout", rule: ChurnRule{Interval: second, Kind: ChurnRollout}},
+		{name: "no interval", rule: ChurnRule{}, wantErr: true},
+		{name: "negative interval", rule: ChurnRule{Interval: -second}, wantErr: true},
+		{name: "unknown kind", rule: ChurnRule{Interval: second, Kind: "other"}, wantErr: true},
+		{name: "template for workloads", rule: ChurnRule{Interval: second, Kind: ChurnWorkloads, Template: "virtualservice"}, wantErr: true},
+		{name: "template for rollout", rule: ChurnRule{Interval: second, Kind: ChurnRollout, Template: "virtualservice"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.rule.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func churnCluster(t *testing.T) *Cluster {
+	t.Helper()
+	cfg, err := ReadConfig(`
+nodes:
+- name: node
+  count: 1
+namespaces:
+- name: a
+  replicas: 2
+  applications:
+  - name: x
+    type: sidecar
+    replicas: 2
+    pods: 1
+    configs:
+    - name: virtualservice
+      refresh: true
+    - name: authorizationpolicy
+      refresh: true
+  - name: y
+    pods: 1
+- name: b
+  applications:
+  - name: x
+    pods: 1
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCluster(ClusterSpec{Config: cfg})
+}
+
+func TestSelectChurnApplications(t *testing.T) {
+	c := churnCluster(t)
+	tests := []struct {
+		name string
+		rule ChurnRule
+		want int
+	}{
+		{name: "all", rule: ChurnRule{}, want: 7},
+		{name: "namespace", rule: ChurnRule{ScenarioSelector: ScenarioSelector{Namespace: "a"}}, want: 6},
+		{name: "application", rule: ChurnRule{ScenarioSelector: ScenarioSelector{Application: "x"}}, want: 5},
+		{name: "type", rule: ChurnRule{Type: model.SidecarType}, want: 4},
+		{name: "namespace and type", rule: ChurnRule{ScenarioSelector: ScenarioSelector{Namespace: "b"}, Type: model.SidecarType}, want: 0},
+		{name: "no match", rule: ChurnRule{ScenarioSelector: ScenarioSelector{Namespace: "missing"}}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := c.selectChurnApplications(tt.rule)
+			if len(got) != tt.want {
+				t.Fatalf("got %d applications, want %d", len(got), tt.want)
+			}
+			for _, a := range got {
+				if tt.rule.Type != "" && a.Spec.Type != tt.rule.Type {
+					t.Fatalf("selected %v of type %v", a.Spec.App, a.Spec.Type)
+				}
+			}
+		})
+	}
+
+	// Deleted namespaces are no longer selected
+	c.namespaces[0].deleted = true
+	if got := c.selectChurnApplications(ChurnRule{Type: model.SidecarType}); len(got) != 2 {
+		t.Fatalf("got %d applications after delete, want 2", len(got))
+	}
+}
+
+func TestSelectChurnConfig(t *testing.T) {
+	c := churnCluster(t)
+	all := c.selectChurnConfig(ChurnRule{})
+	if len(all) != 8 {
+		t.Fatalf("got %d configs, want 8", len(all))
+	}
+	vs := c.selectChurnConfig(ChurnRule{Template: "virtualservice"})
+	if len(vs) != 4 {
+		t.Fatalf("got %d configs, want 4", len(vs))
+	}
+	for _, cfg := range vs {
+		if name := cfg.(*config.Templated).TemplateName(); name != "virtualservice" {
+			t.Fatalf("selected config from template %v", name)
+		}
+	}
+}
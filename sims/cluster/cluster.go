@@ -4,24 +4,33 @@ import (
 	"fmt"
 	"math/rand"
 	"runtime"
+	"slices"
 	"time"
 
 	"istio.io/istio/pkg/kube/controllers"
 	"istio.io/istio/pkg/kube/kclient"
 	"istio.io/istio/pkg/kube/kubetypes"
 	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/ptr"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	klabels "k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/howardjohn/pilot-load/pkg/kube"
 	"github.com/howardjohn/pilot-load/pkg/simulation/app"
 	"github.com/howardjohn/pilot-load/pkg/simulation/model"
 	"github.com/howardjohn/pilot-load/pkg/simulation/util"
+	"github.com/howardjohn/pilot-load/pkg/stats"
 )
 
 type ClusterSpec struct {
-	Config Config
+	// ID of the cluster. Defaults to "Kubernetes"
+	ID      string
+	Network string
+	Config  Config
+	// Shard determines which namespaces and nodes this process owns, when running multiple instances.
+	Shard model.Shard
 }
 
 type Cluster struct {
@@ -30,6 +39,9 @@ type Cluster struct {
 	namespaces []*Namespace
 	nodes      []*Node
 	running    chan struct{}
+	scaler     *ClusterScaler
+	// timeToSynced is the time taken from starting to having all namespaces created
+	timeToSynced time.Duration
 }
 
 func (c *Cluster) GetConfig() any {
@@ -39,26 +51,46 @@ func (c *Cluster) GetConfig() any {
 var _ model.Simulation = &Cluster{}
 
 func NewCluster(s ClusterSpec) *Cluster {
-	cluster := &Cluster{Name: "primary", Spec: &s, running: make(chan struct{})}
+	if s.ID == "" {
+		s.ID = "Kubernetes"
+	}
+	cluster := &Cluster{Name: s.ID, Spec: &s, running: make(chan struct{})}
 
 	needNodes := s.Config.PodCount() / 255
 	if s.Config.NodeCount() < needNodes {
 		log.Fatalf("have %d nodes, but need %d for %d pods", s.Config.NodeCount(), needNodes, s.Config.PodCount())
 	}
+	nodeIdx := 0
 	for _, node := range s.Config.Nodes {
 		for r := 0; r < node.Count; r++ {
+			nodeIdx++
+			if !s.Shard.Owns(nodeIdx - 1) {
+				continue
+			}
 			cluster.nodes = append(cluster.nodes, NewNode(NodeSpec{
 				Name:    fmt.Sprintf("%s-%s", node.Name, util.GenUID()),
 				Region:  "region",
 				Zone:    "zone",
-				Ztunnel: node.Ztunnel != nil,
+				Ztunnel: node.Ztunnel,
+				Cluster: s.ID,
+				Network: s.Network,
 			}))
 		}
 	}
 
+	if len(cluster.nodes) == 0 {
+		log.Fatalf("shard %v owns no nodes; need at least %d nodes", s.Shard, s.Shard.Count)
+	}
+
+	if s.Config.Dependencies != nil && s.Shard.IsSharded() && !s.Config.StableNames {
+		log.Fatalf("dependencies require stableNames when sharded, so every shard generates the same graph")
+	}
+	var dependencies []dependencyNode
+	nsIdx := 0
 	for nsId, ns := range s.Config.Namespaces {
 		for r := 0; r < ns.Replicas; r++ {
-			deployments := ns.Applications
+			nsIdx++
+			deployments := slices.Clone(ns.Applications)
 			for i, d := range ns.Applications {
 				d.GetNode = cluster.SelectNode
 				deployments[i] = d
@@ -67,23 +99,48 @@ func NewCluster(s ClusterSpec) *Cluster {
 			if ns.Replicas > 1 {
 				name = fmt.Sprintf("%s-%s", name, util.GenUIDOrStableIdentifier(s.Config.StableNames, nsId, r))
 			}
-			cluster.namespaces = append(cluster.namespaces, NewNamespace(NamespaceSpec{
+			spec := NamespaceSpec{
 				Name:                name,
+				ConfigName:          util.StringDefault(ns.Name, "namespace"),
 				Deployments:         deployments,
 				TemplateDefinitions: s.Config.Templates,
 				Templates:           ns.Templates,
 				StableNames:         s.Config.StableNames,
 				GracePeriod:         ns.GracePeriod,
 				Waypoint:            ns.Waypoint,
-			}))
+				Cluster:             s.ID,
+				Network:             s.Network,
+			}
+			if !s.Shard.Owns(nsIdx - 1) {
+				// The dependency graph spans all shards, so it includes the namespaces owned by other shards
+				if s.Config.Dependencies != nil {
+					dependencies = append(dependencies, specDependencies(spec)...)
+				}
+				continue
+			}
+			namespace := NewNamespace(spec)
+			cluster.namespaces = append(cluster.namespaces, namespace)
+			dependencies = append(dependencies, namespaceDependencies(namespace)...)
+		}
+	}
+	if s.Config.Dependencies != nil {
+		applyDependencies(*s.Config.Dependencies, dependencies, s.Config.StableNames)
+	}
+	lookups := []string{}
+	for _, ns := range cluster.namespaces {
+		for _, d := range ns.deployments {
+			lookups = append(lookups, d.ServiceKeys()...)
 		}
 	}
+	for _, n := range cluster.nodes {
+		n.Spec.Lookups = lookups
+	}
 	return cluster
 }
 
 func (c *Cluster) GetRefreshableInstances() []*app.Application {
 	var wls []*app.Application
-	for _, ns := range c.namespaces {
+	for _, ns := range c.activeNamespaces() {
 		wls = append(wls, ns.deployments...)
 	}
 	return wls
@@ -91,7 +148,7 @@ func (c *Cluster) GetRefreshableInstances() []*app.Application {
 
 func (c *Cluster) GetRefreshableConfig() []model.RefreshableSimulation {
 	var cfgs []model.RefreshableSimulation
-	for _, ns := range c.namespaces {
+	for _, ns := range c.activeNamespaces() {
 		for _, w := range ns.deployments {
 			for _, cfg := range w.GetConfigs() {
 				if model.IsRefreshable(cfg) {
@@ -116,16 +173,23 @@ func (c *Cluster) getSims() []model.Simulation {
 
 	sims = append(sims, c.getIstioResources()...)
 
-	for _, ns := range c.namespaces {
+	for _, ns := range c.activeNamespaces() {
 		sims = append(sims, ns)
 	}
 	return sims
 }
 
+// activeNamespaces returns the namespaces that have not been deleted by a scenario.
+func (c *Cluster) activeNamespaces() []*Namespace {
+	return slices.DeleteFunc(slices.Clone(c.namespaces), func(ns *Namespace) bool {
+		return ns.deleted
+	})
+}
+
 func (c *Cluster) Run(ctx model.Context) error {
+	t0 := time.Now()
 	// Act as kubelet
-	// TODO: make a leader election mechanism for multi-instance
-	go c.watchPods(ctx)
+	go c.runKubelet(ctx)
 	nodes := []model.Simulation{}
 	for _, ns := range c.nodes {
 		nodes = append(nodes, ns)
@@ -152,9 +216,11 @@ func (c *Cluster) Run(ctx model.Context) error {
 		}
 	}
 
-	log.Infof("cluster %q synced, starting cluster scaler", c.Name)
+	c.timeToSynced = time.Since(t0)
+	log.Infof("cluster %q synced in %v, starting cluster scaler", c.Name, c.timeToSynced)
 	close(c.running)
-	return (&ClusterScaler{Cluster: c}).Run(ctx)
+	c.scaler = &ClusterScaler{Cluster: c}
+	return c.scaler.Run(ctx)
 }
 
 func (c *Cluster) Running() chan struct{} {
@@ -162,14 +228,20 @@ func (c *Cluster) Running() chan struct{} {
 }
 
 func (c *Cluster) Cleanup(ctx model.Context) error {
+	if err := c.scaler.Cleanup(ctx); err != nil {
+		return err
+	}
 	return model.AggregateSimulation{Simulations: model.ReverseSimulations(c.getSims())}.CleanupParallel(ctx)
 }
 
-func (c *Cluster) watchPods(ctx model.Context) {
+// watchPods acts as the kubelet for all fake pods accepted by owns.
+// As ownership may change over time, a signal on resync will re-process all pods.
+func (c *Cluster) watchPods(ctx model.Context, owns func(p *v1.Pod) bool, resync <-chan struct{}) {
 	pods := kclient.NewFiltered[*v1.Pod](ctx.Client, kubetypes.Filter{
 		ObjectTransform: StripPodUnusedFields,
 	})
-	q := NewQueue("pods",
+	var q Queue
+	q = NewQueue("pods",
 		WithWorkers(runtime.GOMAXPROCS(0)),
 		WithReconciler(func(key types.NamespacedName) error {
 			p := pods.Get(key.Name, key.Namespace)
@@ -180,14 +252,41 @@ func (c *Cluster) watchPods(ctx model.Context) {
 				// not our pod
 				return nil
 			}
+			if !owns(p) {
+				// owned by another instance
+				return nil
+			}
 			if p.DeletionTimestamp != nil {
 				if err := pods.Delete(p.Name, p.Namespace); controllers.IgnoreNotFound(err) != nil {
 					return fmt.Errorf("delete: %v", err)
 				}
 				return nil
 			}
+			readiness := c.Spec.Config.Kubelet.Readiness
 			if p.Status.Phase == v1.PodRunning {
-				// no action needed
+				cond := podReadyCondition(p)
+				if cond == nil {
+					return nil
+				}
+				at, ok := readiness.nextTransition(p, cond)
+				if !ok {
+					// no action needed
+					return nil
+				}
+				if wait := time.Until(at); wait > 0 {
+					q.AddAfter(key, wait)
+					return nil
+				}
+				p = p.DeepCopy()
+				ready, reason := cond.Status != v1.ConditionTrue, ""
+				if !ready {
+					reason = reasonProbeFailed
+				}
+				setPodStatus(p, ready, reason)
+				if err := kube.ApplyStatusRealSSA(ctx.Client, p); err != nil {
+					stats.Errors.Inc("kubelet")
+					return fmt.Errorf("apply status: %v", err)
+				}
 				return nil
 			}
 			if p.Spec.NodeName == "" {
@@ -196,41 +295,88 @@ func (c *Cluster) watchPods(ctx model.Context) {
 			}
 			p = p.DeepCopy()
 			p.Status.Phase = v1.PodRunning
-			p.Status.Conditions = nil
-			p.Status.Conditions = append(p.Status.Conditions, v1.PodCondition{
-				Type:               v1.PodReady,
-				Status:             v1.ConditionTrue,
-				LastTransitionTime: metav1.NewTime(time.Now()),
-			})
-			p.Status.Conditions = append(p.Status.Conditions, v1.PodCondition{
-				Type:               v1.ContainersReady,
-				Status:             v1.ConditionTrue,
-				LastTransitionTime: metav1.NewTime(time.Now()),
-			})
 			p.Status.PodIP = util.GetIP()
-			p.Status.ContainerStatuses = make([]v1.ContainerStatus, len(p.Spec.Containers))
-			for i, c := range p.Spec.Containers {
-				p.Status.ContainerStatuses[i] = v1.ContainerStatus{
-					Name: c.Name,
-					State: v1.ContainerState{
-						Running: &v1.ContainerStateRunning{StartedAt: metav1.NewTime(time.Now())},
-					},
-					Ready: true,
-					Image: c.Image,
-				}
-			}
-			p.Spec = v1.PodSpec{}
+			p.Status.StartTime = ptr.Of(metav1.Now().Rfc3339Copy())
+			ready, reason := readiness.initialReadiness(p)
+			setPodStatus(p, ready, reason)
 			if err := kube.ApplyStatusRealSSA(ctx.Client, p); err != nil {
+				stats.Errors.Inc("kubelet")
 				return fmt.Errorf("apply status: %v", err)
 			}
+			if metav1.GetControllerOf(p) != nil {
+				// Pods we create directly are counted when applied; controller pods are only seen here
+				stats.Created.Inc("pod")
+			}
 			return nil
 		}),
 		WithMaxAttempts(5))
 	pods.AddEventHandler(controllers.ObjectHandler(q.AddObject))
 	pods.Start(ctx.Done())
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-resync:
+				for _, p := range pods.List(metav1.NamespaceAll, klabels.Everything()) {
+					q.AddObject(p)
+				}
+			}
+		}
+	}()
 	q.Run(ctx.Done())
 }
 
+// setPodStatus sets the conditions and container statuses of a running pod, for the readiness.
+// The spec is cleared, so only the status is sent when it is applied.
+func setPodStatus(p *v1.Pod, ready bool, reason string) {
+	status := v1.ConditionFalse
+	if ready {
+		status = v1.ConditionTrue
+	}
+	// Truncate the time, so it is the same once read back
+	now := metav1.Now().Rfc3339Copy()
+	p.Status.Conditions = []v1.PodCondition{
+		{
+			Type:               v1.PodReady,
+			Status:             status,
+			Reason:             reason,
+			LastTransitionTime: now,
+		},
+		{
+			Type:               v1.ContainersReady,
+			Status:             status,
+			Reason:             reason,
+			LastTransitionTime: now,
+		},
+	}
+	startedAt := now
+	if p.Status.StartTime != nil {
+		startedAt = *p.Status.StartTime
+	}
+	p.Status.ContainerStatuses = make([]v1.ContainerStatus, len(p.Spec.Containers))
+	for i, c := range p.Spec.Containers {
+		p.Status.ContainerStatuses[i] = v1.ContainerStatus{
+			Name: c.Name,
+			State: v1.ContainerState{
+				Running: &v1.ContainerStateRunning{StartedAt: startedAt},
+			},
+			Ready: ready,
+			Image: c.Image,
+		}
+	}
+	p.Spec = v1.PodSpec{}
+}
+
+func podReadyCondition(p *v1.Pod) *v1.PodCondition {
+	for i, c := range p.Status.Conditions {
+		if c.Type == v1.PodReady {
+			return &p.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
 func (c *Cluster) getIstioResources() []model.Simulation {
 	sims := []model.Simulation{}
 
@@ -263,7 +409,10 @@ func StripPodUnusedFields(obj any) (any, error) {
 			NodeName:           oldSpec.NodeName,
 		}
 		pod.Spec = newSpec
-		pod.Status.Conditions = nil
+		// The ready condition is used to model readiness changes
+		pod.Status.Conditions = slices.DeleteFunc(pod.Status.Conditions, func(c v1.PodCondition) bool {
+			return c.Type != v1.PodReady
+		})
 		pod.Status.InitContainerStatuses = nil
 		pod.Status.ContainerStatuses = nil
 	}
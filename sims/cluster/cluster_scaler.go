@@ -8,13 +8,18 @@ import (
 	"github.com/lthibault/jitterbug"
 	"istio.io/istio/pkg/log"
 
+	"github.com/howardjohn/pilot-load/pkg/simulation/app"
 	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+	"github.com/howardjohn/pilot-load/pkg/stats"
 )
 
 type ClusterScaler struct {
 	Cluster *Cluster
+	ctx     model.Context
 	cancel  context.CancelFunc
 	done    chan struct{}
+	// refreshes counts successful refreshes, by kind
+	refreshes *stats.Counters
 }
 
 func makeTicker(t time.Duration) <-chan time.Time {
@@ -31,54 +36,108 @@ func makeTicker(t time.Duration) <-chan time.Time {
 
 func (s *ClusterScaler) Run(ctx model.Context) error {
 	c, cancel := context.WithCancel(ctx.Context)
+	s.ctx = ctx
 	s.cancel = cancel
 	s.done = make(chan struct{})
+	s.refreshes = stats.NewCounters()
+	actions := make(chan func())
+	// do runs f on the main loop and waits for it to complete. The scenario, churn rules, and autoscalers make all
+	// changes to the cluster through do, so they are serialized with jitter and each other.
+	do := func(f func()) {
+		done := make(chan struct{})
+		select {
+		case actions <- func() {
+			f()
+			close(done)
+		}:
+			<-done
+		case <-c.Done():
+		}
+	}
+	if sc := s.Cluster.Spec.Config.Scenario; sc != nil {
+		go s.runScenario(c, *sc, do)
+	}
+	for _, r := range s.Cluster.Spec.Config.Jitter.Rules {
+		go s.runChurnRule(c, r, do)
+	}
+	for _, a := range s.Cluster.autoscaledApplications() {
+		go s.runAutoscaler(c, a, do)
+	}
 	go func() {
 		defer close(s.done)
 		instanceJitterT := makeTicker(time.Duration(s.Cluster.Spec.Config.Jitter.Workloads))
 		configJitterT := makeTicker(time.Duration(s.Cluster.Spec.Config.Jitter.Config))
 		for {
-			// TODO: more customization around everything here
 			select {
 			case <-c.Done():
 				return
+			case f := <-actions:
+				f()
 			case <-instanceJitterT:
-				wls := s.Cluster.GetRefreshableInstances()
-				if len(wls) == 0 {
-					log.Warnf("no instances to scale")
-					continue
-				}
-				wl := wls[rand.Intn(len(wls))]
-				if info, err := wl.Refresh(ctx); err != nil {
-					log.Errorf("failed to jitter workloads: %v", err)
-				} else {
-					log.Infof("refreshed workload %s (%T)", info, wl)
-				}
+				s.refreshWorkload(s.Cluster.GetRefreshableInstances())
 			case <-configJitterT:
-				cfgs := s.Cluster.GetRefreshableConfig()
-				if len(cfgs) == 0 {
-					log.Warnf("no configs to scale")
-					continue
-				}
-				cfg := cfgs[rand.Intn(len(cfgs))]
-				if info, err := cfg.Refresh(ctx); err != nil {
-					log.Errorf("failed to jitter configs: %v", err)
-				} else {
-					log.Infof("refreshed config %s (%T)", info, cfg)
-				}
+				s.refreshConfig(s.Cluster.GetRefreshableConfig())
 			}
 		}
 	}()
 	return nil
 }
 
+// refreshWorkload refreshes a random workload, returning true if it succeeded.
+func (s *ClusterScaler) refreshWorkload(wls []*app.Application) bool {
+	if len(wls) == 0 {
+		log.Warnf("no instances to scale")
+		return false
+	}
+	wl := wls[rand.Intn(len(wls))]
+	info, err := wl.Refresh(s.ctx)
+	if err != nil {
+		stats.Errors.Inc("refresh-workload")
+		log.Errorf("failed to jitter workloads: %v", err)
+		return false
+	}
+	s.refreshes.Inc("workload")
+	log.Infof("refreshed workload %s (%T)", info, wl)
+	return true
+}
+
+// refreshConfig refreshes a random config, returning true if it succeeded.
+func (s *ClusterScaler) refreshConfig(cfgs []model.RefreshableSimulation) bool {
+	if len(cfgs) == 0 {
+		log.Warnf("no configs to scale")
+		return false
+	}
+	cfg := cfgs[rand.Intn(len(cfgs))]
+	info, err := cfg.Refresh(s.ctx)
+	if err != nil {
+		stats.Errors.Inc("refresh-config")
+		log.Errorf("failed to jitter configs: %v", err)
+		return false
+	}
+	s.refreshes.Inc("config")
+	log.Infof("refreshed config %s (%T)", info, cfg)
+	return true
+}
+
 func (s *ClusterScaler) Cleanup(ctx model.Context) error {
 	if s == nil {
 		return nil
 	}
+	if s.cancel == nil {
+		// Never started
+		return nil
+	}
 	s.cancel()
 	<-s.done
 	return nil
 }
 
+// Refreshes returns the number of successful refreshes performed, by kind.
+func (s *ClusterScaler) Refreshes() map[string]int {
+	if s == nil || s.refreshes == nil {
+		return map[string]int{}
+	}
+	return s.refreshes.Snapshot()
+}
+
 var _ model.Simulation = &ClusterScaler{}
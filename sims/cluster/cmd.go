@@ -4,15 +4,20 @@ import (
 	"fmt"
 
 	"github.com/spf13/pflag"
+	istiokube "istio.io/istio/pkg/kube"
 	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/util/sets"
 
 	"github.com/howardjohn/pilot-load/pkg/flag"
+	"github.com/howardjohn/pilot-load/pkg/kube"
 	"github.com/howardjohn/pilot-load/pkg/simulation/model"
 )
 
 func Command(f *pflag.FlagSet) flag.Command {
 	var cfgFile string
+	var summaryFile string
 	flag.RegisterShort(f, &cfgFile, "config", "c", "config file")
+	flag.Register(f, &summaryFile, "summary", "if set, a JSON summary of the run will be written to this file on exit")
 	return flag.Command{
 		Name:        "cluster",
 		Description: "simulate a full cluster",
@@ -22,16 +27,53 @@ func Command(f *pflag.FlagSet) flag.Command {
 			if err != nil {
 				return nil, fmt.Errorf("failed to read config file: %v", err)
 			}
-			return Build(args, config), nil
+			cluster, err := Build(args, config)
+			if err != nil {
+				return nil, err
+			}
+			cluster.Spec.SummaryFile = summaryFile
+			return cluster, nil
 		},
 	}
 }
 
-func Build(args *model.Args, config Config) *Cluster {
+func Build(args *model.Args, config Config) (*MultiCluster, error) {
 	if len(config.NodeMetadata) > 0 {
 		args.Metadata = config.NodeMetadata
 	}
-	logClusterConfig(config)
-	log.Infof("Starting cluster, total size: %v pods", config.PodCount())
-	return NewCluster(ClusterSpec{Config: config})
+	clients := map[string]*kube.Client{}
+	seen := sets.New[string]()
+	pods := 0
+	for _, cl := range config.Clusters {
+		if cl.Name == "" {
+			return nil, fmt.Errorf("cluster name is required")
+		}
+		if seen.InsertContains(cl.Name) {
+			return nil, fmt.Errorf("duplicate cluster %q", cl.Name)
+		}
+		client, err := clusterClient(args, cl)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build client for cluster %v: %v", cl.Name, err)
+		}
+		clients[cl.Name] = client
+		cfg := config.ForCluster(cl)
+		logClusterConfig(cl.Name, cfg)
+		pods += cfg.PodCount()
+	}
+	log.Infof("Starting %d cluster(s), total size: %v pods", len(config.Clusters), pods)
+	if args.Shard.IsSharded() {
+		log.Infof("Running as shard %v", args.Shard)
+	}
+	return NewMultiCluster(MultiClusterSpec{Config: config, Clients: clients, Shard: args.Shard})
+}
+
+func clusterClient(args *model.Args, cl ClusterConfig) (*kube.Client, error) {
+	switch {
+	case cl.Fake:
+		return kube.NewFakeClient(istiokube.NewFakeClient()), nil
+	case cl.Kubeconfig != "":
+		return kube.NewClient(cl.Kubeconfig, args.KubeQPS)
+	default:
+		return args.Client, nil
+	}
 }
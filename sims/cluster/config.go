@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"text/template"
 
 	"istio.io/istio/pkg/log"
 	"sigs.k8s.io/yaml"
 
+	"github.com/howardjohn/pilot-load/pkg/simulation/app"
 	"github.com/howardjohn/pilot-load/pkg/simulation/model"
 	"github.com/howardjohn/pilot-load/templates"
 )
@@ -25,6 +27,39 @@ type Config struct {
 	StableNames  bool                      `json:"stableNames,omitempty"`
 	NodeMetadata map[string]string         `json:"nodeMetadata,omitempty"`
 	Templates    model.TemplateDefinitions `json:"templates,omitempty"`
+	// Kubelet configures the fake kubelet
+	Kubelet KubeletConfig `json:"kubelet,omitempty"`
+	// Scenario, if set, is a timeline of changes to make once the cluster is synced.
+	Scenario *ScenarioConfig `json:"scenario,omitempty"`
+	// Dependencies, if set, generates a Sidecar for each sidecar application restricting its egress to the services it calls.
+	Dependencies *DependencyConfig `json:"dependencies,omitempty"`
+	// Clusters configures a multicluster simulation. If unset, a single cluster is simulated using the global kubeconfig.
+	Clusters []ClusterConfig `json:"clusters,omitempty"`
+}
+
+// ClusterConfig defines one cluster in a multicluster simulation.
+// Namespaces and nodes default to the top level configuration if unset.
+type ClusterConfig struct {
+	// Name is the cluster ID. This must be unique.
+	Name string `json:"name,omitempty"`
+	// Network the cluster is on. If unset, all clusters are on the same network.
+	Network string `json:"network,omitempty"`
+	// Kubeconfig for the cluster. If unset, the global kubeconfig is used.
+	// This is also used to build the remote secret, so must be reachable from Istiod.
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	// Fake, if set, uses an in-process fake API server. This simulates only the XDS load of the cluster.
+	Fake bool `json:"fake,omitempty"`
+	// Remote indicates the cluster does not run Istiod; it is controlled by the primary clusters.
+	Remote bool `json:"remote,omitempty"`
+	// PilotAddress overrides the Istiod address proxies in the cluster connect to.
+	PilotAddress string            `json:"pilotAddress,omitempty"`
+	Namespaces   []NamespaceConfig `json:"namespaces,omitempty"`
+	Nodes        []NodeConfig      `json:"nodes,omitempty"`
+}
+
+// IsPrimary returns true if the cluster runs Istiod.
+func (c ClusterConfig) IsPrimary() bool {
+	return !c.Remote
 }
 
 type NamespaceConfig struct {
@@ -45,11 +80,30 @@ type ApplicationConfig struct {
 	Labels    map[string]string      `json:"labels,omitempty"`
 	Templates []model.ConfigTemplate `json:"configs,omitempty"`
 	GetNode   func() string          `json:"-"`
+
+	// WorkloadKind determines how pods are created: directly (Pod, the default), or via a Deployment, StatefulSet, or DaemonSet.
+	// Controller backed pods are slower to create, but more realistic. EndpointSlice skips pods entirely.
+	WorkloadKind model.WorkloadKind `json:"workloadKind,omitempty"`
+	// EndpointSlice configures applications with the EndpointSlice workload kind, which write EndpointSlices
+	// directly instead of creating pods. Pods is then the number of endpoints.
+	EndpointSlice app.EndpointSliceConfig `json:"endpointSlice,omitempty"`
+	// Services for the application. If unset, a single service with http and https ports is created.
+	Services []app.ServiceConfig `json:"services,omitempty"`
+	// ServiceAccount for the application's pods. If unset, "default" is used.
+	// The special value "per-app" creates a service account for each application, named after it.
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+	// Autoscale, if set, scales the application's pods over time once the cluster is synced.
+	Autoscale *AutoscaleConfig `json:"autoscale,omitempty"`
 }
 
+// PerAppServiceAccount configures an application to get its own service account.
+const PerAppServiceAccount = "per-app"
+
 type JitterConfig struct {
 	Workloads model.Duration `json:"workloads,omitempty"`
 	Config    model.Duration `json:"config,omitempty"`
+	// Rules are additional targeted churn, each with its own interval.
+	Rules []ChurnRule `json:"rules,omitempty"`
 }
 
 type NodeConfig struct {
@@ -58,7 +112,13 @@ type NodeConfig struct {
 	Count   int                `json:"count,omitempty"`
 }
 
-type NodeZtunnelConfig struct{}
+type NodeZtunnelConfig struct {
+	// OnDemand makes the ztunnel look up services on demand, rather than receiving all addresses.
+	// Lookups are made for the services in the cluster config.
+	OnDemand bool `json:"onDemand,omitempty"`
+	// LookupInterval is the time between each on-demand lookup. If unset, all services are looked up at start.
+	LookupInterval model.Duration `json:"lookupInterval,omitempty"`
+}
 
 func (c Config) ApplyDefaults() Config {
 	cpy := c
@@ -66,7 +126,36 @@ func (c Config) ApplyDefaults() Config {
 	if len(ret.Nodes) == 0 {
 		ret.Nodes = []NodeConfig{{Count: 1, Name: "default"}}
 	}
-	for n, ns := range ret.Namespaces {
+	ret.Namespaces = applyNamespaceDefaults(ret.Namespaces)
+	if len(ret.Clusters) == 0 {
+		ret.Clusters = []ClusterConfig{{Name: "Kubernetes"}}
+	}
+	for i, cl := range ret.Clusters {
+		if len(cl.Nodes) == 0 {
+			cl.Nodes = ret.Nodes
+		}
+		if cl.Namespaces == nil {
+			cl.Namespaces = ret.Namespaces
+		} else {
+			cl.Namespaces = applyNamespaceDefaults(cl.Namespaces)
+		}
+		ret.Clusters[i] = cl
+	}
+	return *ret
+}
+
+// ForCluster returns the configuration for a single cluster.
+func (c Config) ForCluster(cl ClusterConfig) Config {
+	cpy := c
+	cpy.Clusters = nil
+	cpy.Nodes = cl.Nodes
+	cpy.Namespaces = cl.Namespaces
+	return cpy
+}
+
+func applyNamespaceDefaults(namespaces []NamespaceConfig) []NamespaceConfig {
+	ret := slices.Clone(namespaces)
+	for n, ns := range ret {
 		if ns.Replicas == 0 {
 			ns.Replicas = 1
 		}
@@ -79,9 +168,9 @@ func (c Config) ApplyDefaults() Config {
 			}
 			ns.Applications[d] = dp
 		}
-		ret.Namespaces[n] = ns
+		ret[n] = ns
 	}
-	return *ret
+	return ret
 }
 
 func (c Config) PodCount() int {
@@ -112,7 +201,7 @@ var defaultConfig = Config{
 
 func ReadConfigFile(filename string) (Config, error) {
 	if filename == "" {
-		return defaultConfig, nil
+		return defaultConfig.ApplyDefaults(), nil
 	}
 	var bytes []byte
 	var err error
@@ -142,10 +231,39 @@ func ReadConfig(cfgBytes string) (Config, error) {
 		}
 		config.Templates.Inner[k] = v
 	}
+	if err := config.Kubelet.Readiness.Validate(); err != nil {
+		return config, err
+	}
+	if err := config.Dependencies.Validate(); err != nil {
+		return config, err
+	}
+	if err := config.Scenario.Validate(); err != nil {
+		return config, err
+	}
+	for _, r := range config.Jitter.Rules {
+		if err := r.Validate(); err != nil {
+			return config, err
+		}
+	}
+	namespaces := slices.Clone(config.Namespaces)
+	for _, cl := range config.Clusters {
+		namespaces = append(namespaces, cl.Namespaces...)
+	}
+	for _, ns := range namespaces {
+		for _, a := range ns.Applications {
+			if err := a.Autoscale.Validate(); err != nil {
+				return config, fmt.Errorf("application %q: %v", a.Name, err)
+			}
+			if a.Autoscale != nil && a.WorkloadKind == model.DaemonSetKind {
+				// DaemonSets run a pod per node, so cannot be scaled
+				return config, fmt.Errorf("application %q: autoscale is not supported for %v", a.Name, a.WorkloadKind)
+			}
+		}
+	}
 	return config.ApplyDefaults(), nil
 }
 
-func logClusterConfig(config Config) {
+func logClusterConfig(name string, config Config) {
 	namespaces, pods, applications := 0, 0, 0
 	for _, ns := range config.Namespaces {
 		namespaces += ns.Replicas
@@ -154,5 +272,5 @@ func logClusterConfig(config Config) {
 			pods += app.Replicas * app.Pods * ns.Replicas
 		}
 	}
-	log.Infof("Initial configuration: %d namespaces, %d applications, and %d pods", namespaces, applications, pods)
+	log.Infof("Initial configuration for cluster %v: %d namespaces, %d applications, and %d pods", name, namespaces, applications, pods)
 }
@@ -0,0 +1,216 @@
+package cluster
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/util/sets"
+
+	"github.com/howardjohn/pilot-load/pkg/simulation/app"
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+	"github.com/howardjohn/pilot-load/pkg/simulation/util"
+)
+
+type DependencyMode string
+
+const (
+	// RandomDependencies has each application call services chosen uniformly at random.
+	RandomDependencies DependencyMode = "random"
+	// PowerLawDependencies has each application call services chosen by popularity, following a power law,
+	// so a few services are called by most applications.
+	PowerLawDependencies DependencyMode = "power-law"
+	// ExplicitDependencies only uses the configured edges.
+	ExplicitDependencies DependencyMode = "explicit"
+)
+
+// DependencyConfig describes which applications call each other.
+type DependencyConfig struct {
+	// Mode determines how dependencies are generated. Defaults to random.
+	Mode DependencyMode `json:"mode,omitempty"`
+	// Calls is the number of services each application calls, for random and power-law modes. Defaults to 5.
+	Calls int `json:"calls,omitempty"`
+	// Exponent of the power-law distribution. Higher values concentrate calls on fewer services. Defaults to 1.
+	Exponent float64 `json:"exponent,omitempty"`
+	// Edges maps an application name to the names of the applications it calls. Every replica of the application
+	// calls every replica of the targets. These are added to any generated dependencies.
+	Edges map[string][]string `json:"edges,omitempty"`
+}
+
+// Validate checks the config is well formed.
+func (d *DependencyConfig) Validate() error {
+	if d == nil {
+		return nil
+	}
+	switch d.Mode {
+	case "", RandomDependencies, PowerLawDependencies, ExplicitDependencies:
+	default:
+		return fmt.Errorf("dependencies: unknown mode %q", d.Mode)
+	}
+	if d.Calls < 0 {
+		return fmt.Errorf("dependencies: calls must not be negative, got %d", d.Calls)
+	}
+	if d.Exponent < 0 {
+		return fmt.Errorf("dependencies: exponent must not be negative, got %v", d.Exponent)
+	}
+	return nil
+}
+
+// defaultCalls is the number of services each application calls, if not configured.
+const defaultCalls = 5
+
+type dependencyNode struct {
+	// id uniquely identifies the application, as "namespace/name"
+	id string
+	// name is the configured name of the application
+	name    string
+	sidecar bool
+	hosts   []string
+	// app is the application, if it is owned by this shard
+	app *app.Application
+}
+
+func (n dependencyNode) target() bool {
+	return len(n.hosts) > 0
+}
+
+// namespaceDependencies returns the dependency nodes of the namespace's applications.
+func namespaceDependencies(ns *Namespace) []dependencyNode {
+	var nodes []dependencyNode
+	for i, a := range ns.deployments {
+		n := dependencyNode{
+			id:      a.Spec.Namespace + "/" + a.Spec.App,
+			name:    ns.appNames[i],
+			sidecar: a.Spec.Type == model.SidecarType,
+			app:     a,
+		}
+		if a.Spec.Type != model.WaypointType {
+			n.hosts = a.ServiceKeys()
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+// specDependencies returns the dependency nodes of a namespace owned by another shard, without creating it.
+func specDependencies(s NamespaceSpec) []dependencyNode {
+	var nodes []dependencyNode
+	s.applicationReplicas(func(d ApplicationConfig, name string) {
+		n := dependencyNode{
+			id:      s.Name + "/" + name,
+			name:    util.StringDefault(d.Name, "app"),
+			sidecar: d.Type == model.SidecarType,
+		}
+		if d.Type != model.WaypointType {
+			n.hosts = app.ServiceKeysFor(app.ApplicationSpec{
+				App:          name,
+				Namespace:    s.Name,
+				Type:         d.Type,
+				WorkloadKind: d.WorkloadKind,
+				Services:     d.Services,
+			})
+		}
+		nodes = append(nodes, n)
+	})
+	return nodes
+}
+
+// applyDependencies generates the dependency graph between all applications, and scopes each sidecar application
+// owned by this shard to the services it calls.
+func applyDependencies(cfg DependencyConfig, nodes []dependencyNode, stable bool) {
+	r := rand.New(rand.NewSource(rand.Int63()))
+	if stable {
+		// Generate the same graph each run, so results are comparable
+		r = rand.New(rand.NewSource(0))
+	}
+	calls := cfg.Calls
+	if calls == 0 {
+		calls = defaultCalls
+	}
+	var sources, targets []dependencyNode
+	for _, n := range nodes {
+		if n.sidecar {
+			sources = append(sources, n)
+		}
+		if n.target() {
+			targets = append(targets, n)
+		}
+	}
+	// For power-law, popularity is by a random ranking of the targets. cumulative holds the running sum of weights.
+	cumulative := make([]float64, len(targets))
+	exp := cfg.Exponent
+	if exp == 0 {
+		exp = 1
+	}
+	sum := 0.0
+	for i, rank := range r.Perm(len(targets)) {
+		sum += 1 / math.Pow(float64(rank+1), exp)
+		cumulative[i] = sum
+	}
+
+	total, owned := 0, 0
+	for _, src := range sources {
+		called := sets.New[int]()
+		switch cfg.Mode {
+		case ExplicitDependencies:
+		case PowerLawDependencies:
+			called = pickWeighted(r, cumulative, calls, src.id, targets)
+		case RandomDependencies, "":
+			called = pickWeighted(r, nil, calls, src.id, targets)
+		}
+		for _, dst := range cfg.Edges[src.name] {
+			for i, t := range targets {
+				if t.name == dst {
+					called.Insert(i)
+				}
+			}
+		}
+		if src.app == nil {
+			continue
+		}
+		hosts := []string{}
+		for _, i := range sets.SortedList(called) {
+			hosts = append(hosts, targets[i].hosts...)
+		}
+		total += len(hosts)
+		owned++
+		src.app.ScopeSidecar(hosts)
+	}
+	if owned > 0 {
+		log.Infof("generated Sidecars for %d applications, with an average of %.1f hosts (out of %d applications with services)",
+			owned, float64(total)/float64(owned), len(targets))
+	}
+}
+
+// pickWeighted picks n distinct targets, other than self, weighted by the cumulative weights.
+// If cumulative is nil, targets are picked uniformly.
+func pickWeighted(r *rand.Rand, cumulative []float64, n int, self string, targets []dependencyNode) sets.Set[int] {
+	picked := sets.New[int]()
+	candidates := 0
+	for _, t := range targets {
+		if t.id != self {
+			candidates++
+		}
+	}
+	if n >= candidates {
+		for i, t := range targets {
+			if t.id != self {
+				picked.Insert(i)
+			}
+		}
+		return picked
+	}
+	for len(picked) < n {
+		i := r.Intn(len(targets))
+		if cumulative != nil {
+			i = sort.SearchFloat64s(cumulative, r.Float64()*cumulative[len(cumulative)-1])
+		}
+		if targets[i].id == self {
+			continue
+		}
+		picked.Insert(i)
+	}
+	return picked
+}
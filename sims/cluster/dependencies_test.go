@@ -0,0 +1,91 @@
+package cluster
+
+import (
+	"maps"
+	"strings"
+	"testing"
+
+	"github.com/howardjohn/pilot-load/pkg/simulation/config"
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+)
+
+// scopedHosts returns the hosts each sidecar application is scoped to, keyed by "namespace/name".
+func scopedHosts(t *testing.T, c *Cluster) map[string][]string {
+	t.Helper()
+	res := map[string][]string{}
+	for _, ns := range c.namespaces {
+		for _, a := range ns.deployments {
+			for _, cfg := range a.GetConfigs() {
+				if tc, ok := cfg.(*config.Templated); ok && tc.Spec.Name == "sidecar-scoped" {
+					res[a.Spec.Namespace+"/"+a.Spec.App] = tc.Spec.Config["Hosts"].([]string)
+				}
+			}
+		}
+	}
+	return res
+}
+
+func dependencyCluster(t *testing.T, shard model.Shard) *Cluster {
+	t.Helper()
+	cfg, err := ReadConfig(`
+stableNames: true
+nodes:
+- name: node
+  count: 2
+namespaces:
+- name: ns
+  replicas: 4
+  applications:
+  - name: a
+    type: sidecar
+    replicas: 2
+    pods: 1
+  - name: b
+    type: sidecar
+    replicas: 1
+    pods: 1
+dependencies: {}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewCluster(ClusterSpec{Shard: shard, Config: cfg})
+}
+
+func TestDependenciesAcrossShards(t *testing.T) {
+	all := scopedHosts(t, dependencyCluster(t, model.Shard{}))
+	if len(all) != 12 {
+		t.Fatalf("got %d scoped applications, want 12", len(all))
+	}
+	for app, hosts := range all {
+		if len(hosts) != defaultCalls {
+			t.Fatalf("%v: got %d hosts, want the default of %d", app, len(hosts), defaultCalls)
+		}
+	}
+
+	union := map[string][]string{}
+	crossShard := false
+	for i := range 2 {
+		c := dependencyCluster(t, model.Shard{Index: i, Count: 2})
+		owned := map[string]bool{}
+		for _, ns := range c.namespaces {
+			owned[ns.Spec.Name] = true
+		}
+		for app, hosts := range scopedHosts(t, c) {
+			union[app] = hosts
+			for _, h := range hosts {
+				ns, _, _ := strings.Cut(h, "/")
+				if !owned[ns] {
+					crossShard = true
+				}
+			}
+		}
+	}
+	// Each shard scopes its own applications the same as a single process would
+	if !maps.EqualFunc(all, union, func(a, b []string) bool { return strings.Join(a, ",") == strings.Join(b, ",") }) {
+		t.Fatalf("sharded graph differs:\nall:   %v\nunion: %v", all, union)
+	}
+	if !crossShard {
+		t.Fatal("expected applications to call services owned by another shard")
+	}
+}
@@ -0,0 +1,194 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"istio.io/istio/pkg/log"
+	"istio.io/istio/pkg/util/sets"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+	"github.com/howardjohn/pilot-load/pkg/simulation/util"
+)
+
+type KubeletMode string
+
+const (
+	// KubeletModeAll handles all fake pods. This is the default for a single instance.
+	KubeletModeAll KubeletMode = "all"
+	// KubeletModeLeader elects a single instance to handle all fake pods. This is the default when sharded.
+	KubeletModeLeader KubeletMode = "leader"
+	// KubeletModeNode handles only pods on the nodes owned by this instance.
+	KubeletModeNode KubeletMode = "node"
+)
+
+type KubeletConfig struct {
+	// Mode determines which pods this instance acts as the kubelet for, when running multiple instances.
+	Mode KubeletMode `json:"mode,omitempty"`
+	// Readiness models pod readiness. By default, pods are ready as soon as they are running.
+	Readiness ReadinessConfig `json:"readiness,omitempty"`
+}
+
+// ReadinessConfig models pods' readiness probes, to exercise handling of unhealthy endpoints.
+type ReadinessConfig struct {
+	// Delay from a pod running until it is ready.
+	Delay model.Duration `json:"delay,omitempty"`
+	// FlapInterval is the mean time a pod stays ready before its readiness probe fails. If unset, pods do not flap.
+	FlapInterval model.Duration `json:"flapInterval,omitempty"`
+	// FlapDuration is the mean time a pod stays not ready after its readiness probe fails. Defaults to FlapInterval.
+	FlapDuration model.Duration `json:"flapDuration,omitempty"`
+	// NeverReady is the fraction of pods, from 0 to 1, that never become ready.
+	NeverReady float64 `json:"neverReady,omitempty"`
+}
+
+// Reasons set on the PodReady condition, which determine when it next changes
+const (
+	reasonStarting    = "Starting"
+	reasonProbeFailed = "ReadinessProbeFailed"
+	reasonNeverReady  = "NeverReady"
+)
+
+// Validate checks the config is well formed.
+func (r ReadinessConfig) Validate() error {
+	if r.NeverReady < 0 || r.NeverReady > 1 {
+		return fmt.Errorf("kubelet readiness: neverReady must be between 0 and 1, got %v", r.NeverReady)
+	}
+	return nil
+}
+
+// initialReadiness returns whether a newly running pod is ready, and if not, the reason.
+func (r ReadinessConfig) initialReadiness(p *v1.Pod) (bool, string) {
+	if r.neverReady(p) {
+		return false, reasonNeverReady
+	}
+	if r.Delay > 0 {
+		return false, reasonStarting
+	}
+	return true, ""
+}
+
+// nextTransition returns when the pod's readiness, as of the condition, should next change. ok is false if it never will.
+// The times are derived from the pod and condition, so they are stable across reconciles.
+func (r ReadinessConfig) nextTransition(p *v1.Pod, cond *v1.PodCondition) (at time.Time, ok bool) {
+	since := cond.LastTransitionTime.Time
+	if cond.Status == v1.ConditionTrue {
+		if r.FlapInterval <= 0 {
+			return time.Time{}, false
+		}
+		return since.Add(randomDuration(p, since, time.Duration(r.FlapInterval))), true
+	}
+	switch cond.Reason {
+	case reasonStarting:
+		return since.Add(time.Duration(r.Delay)), true
+	case reasonProbeFailed:
+		mean := time.Duration(r.FlapDuration)
+		if mean == 0 {
+			mean = time.Duration(r.FlapInterval)
+		}
+		return since.Add(randomDuration(p, since, mean)), true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func (r ReadinessConfig) neverReady(p *v1.Pod) bool {
+	if r.NeverReady <= 0 {
+		return false
+	}
+	h := fnv.New32a()
+	h.Write([]byte(p.Namespace + "/" + p.Name))
+	return float64(h.Sum32())/math.MaxUint32 < r.NeverReady
+}
+
+// randomDuration returns an exponentially distributed duration with the mean, seeded by the pod and time so
+// each pod flaps independently.
+func randomDuration(p *v1.Pod, since time.Time, mean time.Duration) time.Duration {
+	h := fnv.New64a()
+	h.Write([]byte(p.Namespace + "/" + p.Name))
+	h.Write([]byte(since.String()))
+	r := rand.New(rand.NewSource(int64(h.Sum64())))
+	return time.Duration(r.ExpFloat64() * float64(mean))
+}
+
+const kubeletLeaseName = "pilot-load-kubelet"
+
+func (c *Cluster) kubeletMode() KubeletMode {
+	if m := c.Spec.Config.Kubelet.Mode; m != "" {
+		return m
+	}
+	if c.Spec.Shard.IsSharded() {
+		return KubeletModeLeader
+	}
+	return KubeletModeAll
+}
+
+func (c *Cluster) runKubelet(ctx model.Context) {
+	switch mode := c.kubeletMode(); mode {
+	case KubeletModeAll:
+		c.watchPods(ctx, func(*v1.Pod) bool { return true }, nil)
+	case KubeletModeNode:
+		nodes := sets.New[string]()
+		for _, n := range c.nodes {
+			nodes.Insert(n.Spec.Name)
+		}
+		c.watchPods(ctx, func(p *v1.Pod) bool { return nodes.Contains(p.Spec.NodeName) }, nil)
+	case KubeletModeLeader:
+		leading := atomic.Bool{}
+		resync := make(chan struct{}, 1)
+		go c.runKubeletLeaderElection(ctx, &leading, resync)
+		c.watchPods(ctx, func(*v1.Pod) bool { return leading.Load() }, resync)
+	default:
+		log.Errorf("unknown kubelet mode %q, not running kubelet", mode)
+	}
+}
+
+// runKubeletLeaderElection updates leading to reflect whether this instance is the elected kubelet leader.
+// Upon becoming leader, a resync is triggered to pick up any work missed while not leading.
+func (c *Cluster) runKubeletLeaderElection(ctx model.Context, leading *atomic.Bool, resync chan struct{}) {
+	hostname, _ := os.Hostname()
+	id := hostname + "-" + util.GenUID()
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      kubeletLeaseName,
+			Namespace: "default",
+		},
+		Client: ctx.Client.Kube().CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: id,
+		},
+	}
+	for !util.IsDone(ctx) {
+		leaderelection.RunOrDie(ctx, leaderelection.LeaderElectionConfig{
+			Lock:            lock,
+			LeaseDuration:   15 * time.Second,
+			RenewDeadline:   10 * time.Second,
+			RetryPeriod:     2 * time.Second,
+			ReleaseOnCancel: true,
+			Name:            kubeletLeaseName,
+			Callbacks: leaderelection.LeaderCallbacks{
+				OnStartedLeading: func(context.Context) {
+					log.Infof("cluster %q: elected as kubelet leader (%v)", c.Name, id)
+					leading.Store(true)
+					select {
+					case resync <- struct{}{}:
+					default:
+					}
+				},
+				OnStoppedLeading: func() {
+					log.Infof("cluster %q: no longer kubelet leader (%v)", c.Name, id)
+					leading.Store(false)
+				},
+			},
+		})
+	}
+}
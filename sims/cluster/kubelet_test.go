@@ -0,0 +1,253 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	istiokube "istio.io/istio/pkg/kube"
+	"istio.io/istio/pkg/ptr"
+	"istio.io/istio/pkg/test/util/retry"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/howardjohn/pilot-load/pkg/kube"
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+	"github.com/howardjohn/pilot-load/pkg/stats"
+)
+
+func testPod(name string) *v1.Pod {
+	return &v1.Pod{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns"}}
+}
+
+func readyCondition(status v1.ConditionStatus, reason string, since time.Time) *v1.PodCondition {
+	return &v1.PodCondition{
+		Type:               v1.PodReady,
+		Status:             status,
+		Reason:             reason,
+		LastTransitionTime: metav1.NewTime(since),
+	}
+}
+
+func TestNextTransition(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	minute := model.Duration(time.Minute)
+	tests := []struct {
+		name   string
+		cfg    ReadinessConfig
+		cond   *v1.PodCondition
+		wantOk bool
+		// exact is the expected transition time, if it is not random
+		exact time.Time
+	}{
+		{
+			name:   "ready without flapping",
+			cfg:    ReadinessConfig{},
+			cond:   readyCondition(v1.ConditionTrue, "", since),
+			wantOk: false,
+		},
+		{
+			name:   "ready with flapping",
+			cfg:    ReadinessConfig{FlapInterval: minute},
+			cond:   readyCondition(v1.ConditionTrue, "", since),
+			wantOk: true,
+		},
+		{
+			name:   "starting",
+			cfg:    ReadinessConfig{Delay: minute},
+			cond:   readyCondition(v1.ConditionFalse, reasonStarting, since),
+			wantOk: true,
+			exact:  since.Add(time.Minute),
+		},
+		{
+			name:   "probe failed",
+			cfg:    ReadinessConfig{FlapInterval: minute, FlapDuration: minute},
+			cond:   readyCondition(v1.ConditionFalse, reasonProbeFailed, since),
+			wantOk: true,
+		},
+		{
+			name:   "probe failed defaults to flap interval",
+			cfg:    ReadinessConfig{FlapInterval: minute},
+			cond:   readyCondition(v1.ConditionFalse, reasonProbeFailed, since),
+			wantOk: true,
+		},
+		{
+			name:   "never ready",
+			cfg:    ReadinessConfig{FlapInterval: minute, NeverReady: 1},
+			cond:   readyCondition(v1.ConditionFalse, reasonNeverReady, since),
+			wantOk: false,
+		},
+		{
+			name:   "unknown reason",
+			cfg:    ReadinessConfig{FlapInterval: minute},
+			cond:   readyCondition(v1.ConditionFalse, "Other", since),
+			wantOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testPod("a")
+			at, ok := tt.cfg.nextTransition(p, tt.cond)
+			if ok != tt.wantOk {
+				t.Fatalf("got ok %v, want %v", ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+			if !tt.exact.IsZero() && !at.Equal(tt.exact) {
+				t.Fatalf("got %v, want %v", at, tt.exact)
+			}
+			if at.Before(since) {
+				t.Fatalf("transition %v is before the condition changed at %v", at, since)
+			}
+			// The time must be stable across reconciles
+			if again, _ := tt.cfg.nextTransition(p, tt.cond); !again.Equal(at) {
+				t.Fatalf("got %v then %v, want a stable time", at, again)
+			}
+		})
+	}
+}
+
+func TestNextTransitionFlapsIndependently(t *testing.T) {
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := ReadinessConfig{FlapInterval: model.Duration(time.Minute)}
+	cond := readyCondition(v1.ConditionTrue, "", since)
+	times := map[time.Time]struct{}{}
+	var total time.Duration
+	const pods = 1000
+	for i := range pods {
+		at, _ := cfg.nextTransition(testPod(fmt.Sprintf("pod-%d", i)), cond)
+		times[at] = struct{}{}
+		total += at.Sub(since)
+	}
+	if len(times) < pods*9/10 {
+		t.Fatalf("got %d distinct transition times for %d pods", len(times), pods)
+	}
+	// Exponentially distributed with a mean of the flap interval
+	if mean := total / pods; mean < 50*time.Second || mean > 70*time.Second {
+		t.Fatalf("got mean %v, want about 1m", mean)
+	}
+}
+
+func TestInitialReadiness(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        ReadinessConfig
+		wantReady  bool
+		wantReason string
+	}{
+		{name: "default", cfg: ReadinessConfig{}, wantReady: true},
+		{name: "delay", cfg: ReadinessConfig{Delay: model.Duration(time.Second)}, wantReason: reasonStarting},
+		{name: "never ready", cfg: ReadinessConfig{Delay: model.Duration(time.Second), NeverReady: 1}, wantReason: reasonNeverReady},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ready, reason := tt.cfg.initialReadiness(testPod("a"))
+			if ready != tt.wantReady || reason != tt.wantReason {
+				t.Fatalf("got %v %q, want %v %q", ready, reason, tt.wantReady, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestNeverReadyFraction(t *testing.T) {
+	cfg := ReadinessConfig{NeverReady: 0.2}
+	never := 0
+	const pods = 10000
+	for i := range pods {
+		if cfg.neverReady(testPod(fmt.Sprintf("pod-%d", i))) {
+			never++
+		}
+	}
+	if never < 1700 || never > 2300 {
+		t.Fatalf("got %d of %d pods never ready, want about 20%%", never, pods)
+	}
+}
+
+func TestReadinessValidate(t *testing.T) {
+	for _, v := range []float64{0, 0.5, 1} {
+		if err := (ReadinessConfig{NeverReady: v}).Validate(); err != nil {
+			t.Fatalf("neverReady %v: unexpected error %v", v, err)
+		}
+	}
+	for _, v := range []float64{-0.1, 1.1} {
+		if err := (ReadinessConfig{NeverReady: v}).Validate(); err == nil {
+			t.Fatalf("neverReady %v: expected error", v)
+		}
+	}
+}
+
+func TestSetPodStatus(t *testing.T) {
+	p := testPod("a")
+	p.Spec = v1.PodSpec{
+		NodeName:     "node",
+		NodeSelector: map[string]string{"pilot-load.istio.io/node": "fake"},
+		Containers:   []v1.Container{{Name: "app", Image: "app:1"}, {Name: "proxy", Image: "proxy:1"}},
+	}
+	setPodStatus(p, false, reasonStarting)
+
+	// Only the status is applied, so the spec must not be sent
+	if p.Spec.NodeName != "" || len(p.Spec.Containers) != 0 || len(p.Spec.NodeSelector) != 0 {
+		t.Fatalf("got spec %+v, want it cleared", p.Spec)
+	}
+	cond := podReadyCondition(p)
+	if cond == nil || cond.Status != v1.ConditionFalse || cond.Reason != reasonStarting {
+		t.Fatalf("got ready condition %+v", cond)
+	}
+	if len(p.Status.ContainerStatuses) != 2 {
+		t.Fatalf("got %d container statuses, want 2", len(p.Status.ContainerStatuses))
+	}
+	for i, want := range []string{"app", "proxy"} {
+		cs := p.Status.ContainerStatuses[i]
+		if cs.Name != want || cs.Ready || cs.State.Running == nil {
+			t.Fatalf("got container status %+v", cs)
+		}
+	}
+}
+
+func TestWatchPodsCountsControllerPods(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := kube.NewFakeClient(istiokube.NewFakeClient())
+	mctx := model.Context{Context: ctx, Client: client}
+	fakePod := func(name string, owners ...metav1.OwnerReference) *v1.Pod {
+		return &v1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "ns", OwnerReferences: owners},
+			Spec: v1.PodSpec{
+				NodeName:     "node",
+				NodeSelector: map[string]string{"pilot-load.istio.io/node": "fake"},
+				Containers:   []v1.Container{{Name: "app"}},
+			},
+		}
+	}
+	pods := []*v1.Pod{
+		// Created directly, so already counted when applied
+		fakePod("direct"),
+		fakePod("controlled", metav1.OwnerReference{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "rs", UID: "rs", Controller: ptr.Of(true)}),
+	}
+	for _, p := range pods {
+		if _, err := client.Kube().CoreV1().Pods(p.Namespace).Create(ctx, p, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	before := stats.Created.Get("pod")
+	c := &Cluster{Spec: &ClusterSpec{}}
+	go c.watchPods(mctx, func(*v1.Pod) bool { return true }, nil)
+
+	retry.UntilSuccessOrFail(t, func() error {
+		for _, p := range pods {
+			got, err := client.Kube().CoreV1().Pods(p.Namespace).Get(ctx, p.Name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if got.Status.Phase != v1.PodRunning {
+				return fmt.Errorf("pod %v is %v", p.Name, got.Status.Phase)
+			}
+		}
+		return nil
+	}, retry.Timeout(10*time.Second))
+	if got := stats.Created.Get("pod") - before; got != 1 {
+		t.Fatalf("got %d pods counted, want only the controller pod", got)
+	}
+}
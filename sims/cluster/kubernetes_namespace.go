@@ -10,6 +10,7 @@ import (
 
 	"github.com/howardjohn/pilot-load/pkg/kube"
 	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+	"github.com/howardjohn/pilot-load/pkg/stats"
 )
 
 type KubernetesNamespaceSpec struct {
@@ -32,6 +33,7 @@ func (n *KubernetesNamespace) Run(ctx model.Context) error {
 	// Retry in case it is still finalizing
 	for range 100 {
 		if err = kube.Apply(ctx.Client, n.getKubernetesNamespace()); err == nil {
+			stats.Created.Inc("namespace")
 			return nil
 		}
 		log.Warnf("namespace failed, retrying...: %v", err)
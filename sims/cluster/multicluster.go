@@ -0,0 +1,137 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sync/errgroup"
+	"istio.io/istio/pkg/log"
+
+	"github.com/howardjohn/pilot-load/pkg/kube"
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+)
+
+type MultiClusterSpec struct {
+	Config Config
+	// Clients holds the kube client for each cluster, keyed by cluster ID
+	Clients map[string]*kube.Client
+	// SummaryFile, if set, is where a JSON summary of the run is written on cleanup
+	SummaryFile string
+	// Shard determines the portion of each cluster owned by this process, when running multiple instances.
+	Shard model.Shard
+}
+
+// MultiCluster runs one or more clusters, wiring up remote secrets between them.
+// A single cluster configuration is simply a MultiCluster with one member.
+type MultiCluster struct {
+	Spec    *MultiClusterSpec
+	members []*member
+}
+
+type member struct {
+	config  ClusterConfig
+	client  *kube.Client
+	cluster *Cluster
+	// secrets are the remote secrets, for other clusters, created in this cluster
+	secrets []*RemoteSecret
+}
+
+var _ model.DebuggableSimulation = &MultiCluster{}
+
+func NewMultiCluster(s MultiClusterSpec) (*MultiCluster, error) {
+	m := &MultiCluster{Spec: &s}
+	for _, cl := range s.Config.Clusters {
+		m.members = append(m.members, &member{
+			config: cl,
+			client: s.Clients[cl.Name],
+			cluster: NewCluster(ClusterSpec{
+				ID:      cl.Name,
+				Network: cl.Network,
+				Config:  s.Config.ForCluster(cl),
+				Shard:   s.Shard,
+			}),
+		})
+	}
+
+	// Istiod in each primary needs a remote secret for every other cluster.
+	for _, remote := range m.members {
+		if remote.config.Kubeconfig == "" || remote.config.Fake {
+			continue
+		}
+		kc, err := os.ReadFile(remote.config.Kubeconfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read kubeconfig for cluster %v: %v", remote.config.Name, err)
+		}
+		for _, primary := range m.members {
+			if primary == remote || !primary.config.IsPrimary() {
+				continue
+			}
+			primary.secrets = append(primary.secrets, NewRemoteSecret(RemoteSecretSpec{
+				Cluster:    remote.config.Name,
+				Kubeconfig: kc,
+			}))
+		}
+	}
+	return m, nil
+}
+
+func (m *MultiCluster) GetConfig() any {
+	return m.Spec.Config
+}
+
+// contextFor returns a context scoped to the cluster: all API calls go to its API server, and proxies
+// identify themselves as part of the cluster.
+func (m *MultiCluster) contextFor(ctx model.Context, mem *member) model.Context {
+	ctx.Client = mem.client
+	ctx.Args.Client = mem.client
+	auth := *ctx.Args.Auth
+	auth.Client = mem.client
+	auth.ClusterID = mem.config.Name
+	ctx.Args.Auth = &auth
+	if mem.config.PilotAddress != "" {
+		ctx.Args.PilotAddress = mem.config.PilotAddress
+	}
+	return ctx
+}
+
+func (m *MultiCluster) Run(ctx model.Context) error {
+	for _, mem := range m.members {
+		cctx := m.contextFor(ctx, mem)
+		for _, s := range mem.secrets {
+			if err := s.Run(cctx); err != nil {
+				return fmt.Errorf("failed to create remote secret in cluster %v: %v", mem.config.Name, err)
+			}
+		}
+	}
+	g := errgroup.Group{}
+	for _, mem := range m.members {
+		g.Go(func() error {
+			if err := mem.cluster.Run(m.contextFor(ctx, mem)); err != nil {
+				return fmt.Errorf("cluster %v: %v", mem.config.Name, err)
+			}
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+func (m *MultiCluster) Cleanup(ctx model.Context) error {
+	g := errgroup.Group{}
+	errs := make([]error, len(m.members))
+	for i, mem := range m.members {
+		g.Go(func() error {
+			cctx := m.contextFor(ctx, mem)
+			errs[i] = mem.cluster.Cleanup(cctx)
+			for _, s := range mem.secrets {
+				errs[i] = errors.Join(errs[i], s.Cleanup(cctx))
+			}
+			return nil
+		})
+	}
+	_ = g.Wait()
+	if err := m.reportSummary(); err != nil {
+		log.Warnf("failed to write summary: %v", err)
+	}
+	return errors.Join(errs...)
+}
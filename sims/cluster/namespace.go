@@ -14,13 +14,17 @@ import (
 )
 
 type NamespaceSpec struct {
-	Name                string
+	Name string
+	// ConfigName is the name of the namespace in the cluster config, shared by all replicas.
+	ConfigName          string
 	TemplateDefinitions model.TemplateDefinitions
 	Deployments         []ApplicationConfig
 	Templates           []model.ConfigTemplate
 	StableNames         bool
 	Waypoint            string
 	GracePeriod         model.Duration
+	Cluster             string
+	Network             string
 }
 
 type Namespace struct {
@@ -28,7 +32,13 @@ type Namespace struct {
 	ns          *KubernetesNamespace
 	sa          map[string]*app.ServiceAccount
 	deployments []*app.Application
-	configs     []*config.Templated
+	// appNames holds the configured name of each deployment
+	appNames []string
+	// autoscale holds the autoscaler of each deployment, if any
+	autoscale []*AutoscaleConfig
+	configs   []*config.Templated
+	// deleted is set once the namespace is removed by a scenario
+	deleted bool
 }
 
 var _ model.Simulation = &Namespace{}
@@ -47,12 +57,17 @@ func NewNamespace(s NamespaceSpec) *Namespace {
 		}
 		cfg[config.Namespace] = s.Name
 		ns.configs = append(ns.configs, config.NewTemplated(config.TemplatedSpec{
+			Name:     tmpl.Name,
 			Template: s.TemplateDefinitions.Get(tmpl.Name),
 			Config:   cfg,
 			Refresh:  tmpl.Refresh,
 		}))
 	}
 
+	if s.Network != "" {
+		nsLabels["topology.istio.io/network"] = s.Network
+	}
+
 	if s.Waypoint != "" {
 		ns, name, ok := strings.Cut(s.Waypoint, "/")
 		if ok {
@@ -74,27 +89,55 @@ func NewNamespace(s NamespaceSpec) *Namespace {
 		}),
 	}
 
+	s.applicationReplicas(func(d ApplicationConfig, name string) {
+		ns.deployments = append(ns.deployments, ns.createApplication(d, name))
+		ns.appNames = append(ns.appNames, util.StringDefault(d.Name, "app"))
+		ns.autoscale = append(ns.autoscale, d.Autoscale)
+	})
+	return ns
+}
+
+// applicationReplicas calls fn with each replica of the namespace's applications, and the name it is created with.
+func (s *NamespaceSpec) applicationReplicas(fn func(d ApplicationConfig, name string)) {
 	for idx, d := range s.Deployments {
 		for r := range d.Replicas {
 			suffix := util.GenUIDOrStableIdentifier(s.StableNames, idx, r)
 			if d.Type == model.WaypointType {
 				suffix = "static"
 			}
-			ns.deployments = append(ns.deployments, ns.createApplication(d, suffix))
+			fn(d, fmt.Sprintf("%s-%s", util.StringDefault(d.Name, "app"), suffix))
 		}
 	}
-	return ns
 }
 
-func (n *Namespace) createApplication(args ApplicationConfig, suffix string) *app.Application {
+// serviceAccountFor returns the service account for the application, creating it if needed.
+func (n *Namespace) serviceAccountFor(args ApplicationConfig, appName string) string {
+	sa := util.StringDefault(args.ServiceAccount, "default")
+	if sa == PerAppServiceAccount {
+		sa = appName
+	}
+	if _, f := n.sa[sa]; !f {
+		n.sa[sa] = app.NewServiceAccount(app.ServiceAccountSpec{
+			Namespace: n.Spec.Name,
+			Name:      sa,
+		})
+	}
+	return sa
+}
+
+func (n *Namespace) createApplication(args ApplicationConfig, name string) *app.Application {
 	return app.NewApplication(app.ApplicationSpec{
-		App:       fmt.Sprintf("%s-%s", util.StringDefault(args.Name, "app"), suffix),
-		Node:      args.GetNode,
-		Namespace: n.Spec.Name,
-		// TODO implement different service accounts
-		ServiceAccount:      "default",
+		App:                 name,
+		Node:                args.GetNode,
+		Namespace:           n.Spec.Name,
+		ServiceAccount:      n.serviceAccountFor(args, name),
+		Cluster:             n.Spec.Cluster,
+		Network:             n.Spec.Network,
 		Instances:           args.Pods,
 		Type:                args.Type,
+		WorkloadKind:        args.WorkloadKind,
+		EndpointSlice:       args.EndpointSlice,
+		Services:            args.Services,
 		Templates:           args.Templates,
 		TemplateDefinitions: n.Spec.TemplateDefinitions,
 		Labels:              args.Labels,
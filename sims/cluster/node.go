@@ -12,6 +12,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 
+	"github.com/howardjohn/pilot-load/adsc"
 	"github.com/howardjohn/pilot-load/pkg/kube"
 	"github.com/howardjohn/pilot-load/pkg/simulation/model"
 	"github.com/howardjohn/pilot-load/pkg/simulation/util"
@@ -22,7 +23,11 @@ type NodeSpec struct {
 	Name    string
 	Region  string
 	Zone    string
-	Ztunnel bool
+	Ztunnel *NodeZtunnelConfig
+	// Lookups are the addresses a ztunnel may look up on demand.
+	Lookups []string
+	Cluster string
+	Network string
 }
 
 type Node struct {
@@ -62,17 +67,23 @@ func (n *Node) Run(ctx model.Context) (err error) {
 			}
 		}
 	}()
-	if n.Spec.Ztunnel {
+	if n.Spec.Ztunnel != nil {
 		n.xds = &xds.Simulation{
 			Labels:    nil,
 			Namespace: "istio-system",
 			Name:      "ztunnel-" + n.Spec.Name,
 			IP:        util.GetIP(),
 			AppType:   model.ZtunnelType,
-			// TODO: multicluster
-			Cluster:  "Kubernetes",
-			GrpcOpts: ctx.Args.Auth.GrpcOptions("ztunnel", "istio-system"),
-			Delta:    true,
+			Cluster:   n.Spec.Cluster,
+			Network:   n.Spec.Network,
+			Metadata:  map[string]string{"NODE_NAME": n.Spec.Name},
+			GrpcOpts:  ctx.Args.Auth.GrpcOptions("ztunnel", "istio-system"),
+			Delta:     true,
+			Ztunnel: adsc.ZtunnelConfig{
+				OnDemand:       n.Spec.Ztunnel.OnDemand,
+				Lookups:        n.Spec.Lookups,
+				LookupInterval: time.Duration(n.Spec.Ztunnel.LookupInterval),
+			},
 		}
 		return n.xds.Run(ctx)
 	}
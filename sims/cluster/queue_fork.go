@@ -16,6 +16,7 @@ package cluster
 
 import (
 	"fmt"
+	"time"
 
 	"go.uber.org/atomic"
 	"istio.io/istio/pkg/config"
@@ -106,6 +107,11 @@ func (q Queue) Add(item any) {
 	q.queue.Add(item)
 }
 
+// AddAfter adds an item to the queue once the duration has passed.
+func (q Queue) AddAfter(item any, d time.Duration) {
+	q.queue.AddAfter(item, d)
+}
+
 // AddObject takes an Object and adds the types.NamespacedName associated.
 func (q Queue) AddObject(obj controllers.Object) {
 	q.queue.Add(config.NamespacedName(obj))
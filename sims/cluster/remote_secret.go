@@ -0,0 +1,53 @@
+package cluster
+
+import (
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/howardjohn/pilot-load/pkg/kube"
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+)
+
+type RemoteSecretSpec struct {
+	// Cluster is the ID of the cluster the secret grants access to
+	Cluster    string
+	Kubeconfig []byte
+}
+
+// RemoteSecret grants Istiod access to another cluster, equivalent to `istioctl create-remote-secret`.
+type RemoteSecret struct {
+	Spec *RemoteSecretSpec
+}
+
+var _ model.Simulation = &RemoteSecret{}
+
+func NewRemoteSecret(s RemoteSecretSpec) *RemoteSecret {
+	return &RemoteSecret{Spec: &s}
+}
+
+func (r *RemoteSecret) Run(ctx model.Context) error {
+	return kube.Apply(ctx.Client, r.getSecret())
+}
+
+func (r *RemoteSecret) Cleanup(ctx model.Context) error {
+	return kube.Delete(ctx.Client, r.getSecret())
+}
+
+func (r *RemoteSecret) getSecret() *v1.Secret {
+	s := r.Spec
+	return &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "istio-remote-secret-" + s.Cluster,
+			Namespace: "istio-system",
+			Labels: map[string]string{
+				"istio/multiCluster": "true",
+			},
+			Annotations: map[string]string{
+				"networking.istio.io/cluster": s.Cluster,
+			},
+		},
+		Data: map[string][]byte{
+			s.Cluster: s.Kubeconfig,
+		},
+	}
+}
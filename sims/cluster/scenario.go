@@ -0,0 +1,259 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"istio.io/istio/pkg/log"
+
+	"github.com/howardjohn/pilot-load/pkg/simulation/app"
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+	"github.com/howardjohn/pilot-load/pkg/simulation/util"
+	"github.com/howardjohn/pilot-load/pkg/stats"
+)
+
+// ScenarioConfig is a timeline of phases run once the cluster is synced, turning a load test into a reproducible script.
+// Jitter continues to run alongside the scenario.
+type ScenarioConfig struct {
+	Phases []ScenarioPhase `json:"phases,omitempty"`
+	// Repeat runs the phases again once all are complete, until the simulation is stopped.
+	Repeat bool `json:"repeat,omitempty"`
+}
+
+// ScenarioPhase is a single step in a scenario. At most one action may be set; a phase with no action holds the
+// current state for its duration.
+type ScenarioPhase struct {
+	Name string `json:"name,omitempty"`
+	// Duration of the phase. Actions are spread over the duration, and the next phase starts once it has elapsed.
+	Duration model.Duration `json:"duration,omitempty"`
+	// Scale changes the number of pods for each selected application, ramping linearly over the duration.
+	Scale *ScalePhase `json:"scale,omitempty"`
+	// Churn refreshes selected workloads and configs at a fixed rate for the duration.
+	Churn *ChurnPhase `json:"churn,omitempty"`
+	// Delete removes the selected namespaces.
+	Delete *DeletePhase `json:"delete,omitempty"`
+}
+
+// ScenarioSelector selects applications by their names in the config. Empty fields match everything.
+type ScenarioSelector struct {
+	Namespace   string `json:"namespace,omitempty"`
+	Application string `json:"application,omitempty"`
+}
+
+type ScalePhase struct {
+	ScenarioSelector `json:",inline"`
+	// Pods is the number of pods each selected application has at the end of the phase.
+	Pods int `json:"pods"`
+}
+
+// DeletePhase selects namespaces to remove. Either a namespace or a count is required, so an empty phase does
+// not remove everything.
+type DeletePhase struct {
+	ScenarioSelector `json:",inline"`
+	// Count limits the number of namespaces removed. If unset, all matching namespaces are removed.
+	Count int `json:"count,omitempty"`
+}
+
+type ChurnPhase struct {
+	ScenarioSelector `json:",inline"`
+	// Workloads is the number of workload refreshes per second.
+	Workloads float64 `json:"workloads,omitempty"`
+	// Configs is the number of config refreshes per second.
+	Configs float64 `json:"configs,omitempty"`
+}
+
+// Validate checks the scenario is well formed.
+func (s *ScenarioConfig) Validate() error {
+	if s == nil {
+		return nil
+	}
+	var total model.Duration
+	for i, p := range s.Phases {
+		total += p.Duration
+		actions := 0
+		for _, set := range []bool{p.Scale != nil, p.Churn != nil, p.Delete != nil} {
+			if set {
+				actions++
+			}
+		}
+		if actions > 1 {
+			return fmt.Errorf("scenario phase %d %q: at most one of scale, churn, and delete may be set", i+1, p.Name)
+		}
+		if p.Churn != nil && p.Duration == 0 {
+			return fmt.Errorf("scenario phase %d %q: churn requires a duration", i+1, p.Name)
+		}
+		if d := p.Delete; d != nil {
+			if d.Namespace == "" && d.Count <= 0 {
+				return fmt.Errorf("scenario phase %d %q: delete requires a namespace or count", i+1, p.Name)
+			}
+			if d.Application != "" {
+				return fmt.Errorf("scenario phase %d %q: delete selects namespaces, not applications", i+1, p.Name)
+			}
+		}
+	}
+	if s.Repeat && total <= 0 {
+		// Otherwise the phases would repeat in a busy loop
+		return fmt.Errorf("scenario: repeat requires phases with a total duration")
+	}
+	return nil
+}
+
+func (s ScenarioSelector) String() string {
+	return fmt.Sprintf("%s/%s", util.StringDefault(s.Namespace, "*"), util.StringDefault(s.Application, "*"))
+}
+
+func (s ScenarioSelector) matchesNamespace(ns *Namespace) bool {
+	return !ns.deleted && (s.Namespace == "" || s.Namespace == ns.Spec.ConfigName)
+}
+
+// selectApplications returns the applications matching the selector.
+func (c *Cluster) selectApplications(s ScenarioSelector) []*app.Application {
+	var res []*app.Application
+	for _, ns := range c.namespaces {
+		if !s.matchesNamespace(ns) {
+			continue
+		}
+		for i, a := range ns.deployments {
+			if s.Application == "" || s.Application == ns.appNames[i] {
+				res = append(res, a)
+			}
+		}
+	}
+	return res
+}
+
+// runScenario runs the scenario, repeating it if configured.
+func (s *ClusterScaler) runScenario(ctx context.Context, cfg ScenarioConfig, do func(func())) {
+	for {
+		for i, p := range cfg.Phases {
+			log.Infof("scenario: starting phase %d %q", i+1, p.Name)
+			if !s.runPhase(ctx, p, do) {
+				return
+			}
+		}
+		if !cfg.Repeat {
+			log.Infof("scenario: complete")
+			return
+		}
+	}
+}
+
+// runPhase runs a single phase, returning false if the context ended first.
+func (s *ClusterScaler) runPhase(ctx context.Context, p ScenarioPhase, do func(func())) bool {
+	dur := time.Duration(p.Duration)
+	end := time.After(dur)
+	switch {
+	case p.Scale != nil:
+		var apps []*app.Application
+		var from []int
+		do(func() {
+			apps = s.Cluster.selectApplications(p.Scale.ScenarioSelector)
+			for _, a := range apps {
+				from = append(from, a.Replicas())
+			}
+		})
+		if ctx.Err() != nil {
+			return false
+		}
+		if len(apps) == 0 {
+			log.Warnf("scenario: no applications match %v", p.Scale.ScenarioSelector)
+			break
+		}
+		steps := 0
+		for _, f := range from {
+			steps = max(steps, abs(p.Scale.Pods-f))
+		}
+		// Take at most one step per second
+		steps = max(min(steps, int(dur/time.Second)), 1)
+		for step := 1; step <= steps; step++ {
+			if !sleepCtx(ctx, dur/time.Duration(steps)) {
+				return false
+			}
+			do(func() {
+				for i, a := range apps {
+					target := from[i] + (p.Scale.Pods-from[i])*step/steps
+					if err := a.ScaleTo(s.ctx, target); err != nil {
+						s.fail("scale", err)
+					}
+				}
+			})
+		}
+	case p.Churn != nil:
+		workloads := rateTicker(p.Churn.Workloads)
+		configs := rateTicker(p.Churn.Configs)
+		defer workloads.Stop()
+		defer configs.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return false
+			case <-end:
+				return true
+			case <-workloads.C:
+				do(func() {
+					s.refreshWorkload(s.Cluster.selectApplications(p.Churn.ScenarioSelector))
+				})
+			case <-configs.C:
+				do(func() {
+					s.refreshConfig(s.Cluster.selectChurnConfig(ChurnRule{ScenarioSelector: p.Churn.ScenarioSelector}))
+				})
+			}
+		}
+	case p.Delete != nil:
+		do(func() {
+			deleted := 0
+			for _, ns := range s.Cluster.namespaces {
+				if p.Delete.Count > 0 && deleted >= p.Delete.Count {
+					break
+				}
+				if !p.Delete.matchesNamespace(ns) {
+					continue
+				}
+				deleted++
+				log.Infof("scenario: deleting namespace %v", ns.Spec.Name)
+				if err := ns.Cleanup(s.ctx); err != nil {
+					s.fail("delete", err)
+				}
+				ns.deleted = true
+			}
+		})
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-end:
+		return true
+	}
+}
+
+func (s *ClusterScaler) fail(action string, err error) {
+	stats.Errors.Inc("scenario-" + action)
+	log.Errorf("scenario: %v failed: %v", action, err)
+}
+
+// rateTicker returns a ticker firing rate times per second. If rate is zero, it never fires.
+func rateTicker(rate float64) *time.Ticker {
+	if rate <= 0 {
+		t := time.NewTicker(time.Hour)
+		t.Stop()
+		return t
+	}
+	return time.NewTicker(time.Duration(float64(time.Second) / rate))
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
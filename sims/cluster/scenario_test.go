@@ -0,0 +1,90 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+)
+
+func TestScenarioValidate(t *testing.T) {
+	second := model.Duration(time.Second)
+	tests := []struct {
+		name    string
+		cfg     *ScenarioConfig
+		wantErr bool
+	}{
+		{name: "nil", cfg: nil},
+		{name: "hold", cfg: &ScenarioConfig{Phases: []ScenarioPhase{{Duration: second}}}},
+		{name: "scale", cfg: &ScenarioConfig{Phases: []ScenarioPhase{{Scale: &ScalePhase{Pods: 2}}}}},
+		{
+			name:    "multiple actions",
+			cfg:     &ScenarioConfig{Phases: []ScenarioPhase{{Duration: second, Scale: &ScalePhase{}, Churn: &ChurnPhase{}}}},
+			wantErr: true,
+		},
+		{name: "churn", cfg: &ScenarioConfig{Phases: []ScenarioPhase{{Duration: second, Churn: &ChurnPhase{Workloads: 1}}}}},
+		{name: "churn without duration", cfg: &ScenarioConfig{Phases: []ScenarioPhase{{Churn: &ChurnPhase{Workloads: 1}}}}, wantErr: true},
+		{name: "delete namespace", cfg: &ScenarioConfig{Phases: []ScenarioPhase{{Delete: &DeletePhase{ScenarioSelector: ScenarioSelector{Namespace: "ns"}}}}}},
+		{name: "delete count", cfg: &ScenarioConfig{Phases: []ScenarioPhase{{Delete: &DeletePhase{Count: 1}}}}},
+		{name: "unbounded delete", cfg: &ScenarioConfig{Phases: []ScenarioPhase{{Delete: &DeletePhase{}}}}, wantErr: true},
+		{
+			name:    "delete application",
+			cfg:     &ScenarioConfig{Phases: []ScenarioPhase{{Delete: &DeletePhase{ScenarioSelector: ScenarioSelector{Namespace: "ns", Application: "app"}}}}},
+			wantErr: true,
+		},
+		{name: "repeat", cfg: &ScenarioConfig{Repeat: true, Phases: []ScenarioPhase{{}, {Duration: second}}}},
+		{name: "repeat without duration", cfg: &ScenarioConfig{Repeat: true, Phases: []ScenarioPhase{{Scale: &ScalePhase{Pods: 2}}}}, wantErr: true},
+		{name: "repeat without phases", cfg: &ScenarioConfig{Repeat: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Fatalf("got err %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunScenario(t *testing.T) {
+	// Scale phases select their applications once through do, so calls to do count the phases run
+	phase := ScenarioPhase{Duration: model.Duration(time.Millisecond), Scale: &ScalePhase{ScenarioSelector: ScenarioSelector{Namespace: "missing"}}}
+	tests := []struct {
+		name   string
+		repeat bool
+		// stopAfter cancels the scenario after this many phases, if set
+		stopAfter int
+		want      int
+	}{
+		{name: "once", want: 2},
+		{name: "repeat", repeat: true, stopAfter: 5, want: 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			s := &ClusterScaler{Cluster: &Cluster{Spec: &ClusterSpec{}}}
+			phases := 0
+			do := func(f func()) {
+				f()
+				phases++
+				if phases == tt.stopAfter {
+					cancel()
+				}
+			}
+			done := make(chan struct{})
+			go func() {
+				s.runScenario(ctx, ScenarioConfig{Repeat: tt.repeat, Phases: []ScenarioPhase{phase, phase}}, do)
+				close(done)
+			}()
+			select {
+			case <-done:
+			case <-time.After(10 * time.Second):
+				t.Fatal("scenario did not complete")
+			}
+			if phases != tt.want {
+				t.Fatalf("got %d phases run, want %d", phases, tt.want)
+			}
+		})
+	}
+}
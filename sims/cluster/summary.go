@@ -0,0 +1,115 @@
+package cluster
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/howardjohn/pilot-load/pkg/stats"
+)
+
+// Summary describes the outcome of a cluster simulation run. Durations are reported in nanoseconds.
+type Summary struct {
+	Clusters     []ClusterSummary  `json:"clusters"`
+	Namespaces   int               `json:"namespaces"`
+	Applications int               `json:"applications"`
+	Pods         int               `json:"pods"`
+	ApplyLatency stats.Percentiles `json:"applyLatency"`
+	XDSConnect   stats.Percentiles `json:"xdsConnect"`
+	XDSFirstPush stats.Percentiles `json:"xdsFirstPush"`
+	Errors       map[string]int    `json:"errors"`
+}
+
+type ClusterSummary struct {
+	Name         string         `json:"name"`
+	TimeToSynced time.Duration  `json:"timeToSynced"`
+	Refreshes    map[string]int `json:"refreshes"`
+}
+
+func (c *Cluster) Summary() ClusterSummary {
+	return ClusterSummary{
+		Name:         c.Name,
+		TimeToSynced: c.timeToSynced,
+		Refreshes:    c.scaler.Refreshes(),
+	}
+}
+
+func (m *MultiCluster) Summary() Summary {
+	s := Summary{
+		Namespaces:   stats.Created.Get("namespace"),
+		Applications: stats.Created.Get("application"),
+		Pods:         stats.Created.Get("pod"),
+		ApplyLatency: stats.ApplyLatency.Percentiles(),
+		XDSConnect:   stats.XDSConnect.Percentiles(),
+		XDSFirstPush: stats.XDSFirstPush.Percentiles(),
+		Errors:       stats.Errors.Snapshot(),
+	}
+	for _, mem := range m.members {
+		s.Clusters = append(s.Clusters, mem.cluster.Summary())
+	}
+	return s
+}
+
+// reportSummary prints the summary of the run, and writes it to the summary file, if configured.
+func (m *MultiCluster) reportSummary() error {
+	s := m.Summary()
+	s.Print()
+	if m.Spec.SummaryFile == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(m.Spec.SummaryFile, b, 0o644)
+}
+
+func (s Summary) Print() {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Println()
+	fmt.Fprintf(w, "Created:\tnamespaces=%d\tapplications=%d\tpods=%d\n", s.Namespaces, s.Applications, s.Pods)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Cluster\tSynced\tRefreshes")
+	for _, c := range s.Clusters {
+		synced := "not synced"
+		if c.TimeToSynced > 0 {
+			synced = c.TimeToSynced.Truncate(time.Millisecond).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Name, synced, formatCounts(c.Refreshes))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Latency\tCount\tP50\tP90\tP99\tMax")
+	for _, l := range []struct {
+		name string
+		p    stats.Percentiles
+	}{
+		{"apply", s.ApplyLatency},
+		{"xds connect", s.XDSConnect},
+		{"xds first push", s.XDSFirstPush},
+	} {
+		fmt.Fprintf(w, "%s\t%d\t%v\t%v\t%v\t%v\n", l.name, l.p.Count,
+			l.p.P50.Truncate(time.Microsecond), l.p.P90.Truncate(time.Microsecond),
+			l.p.P99.Truncate(time.Microsecond), l.p.Max.Truncate(time.Microsecond))
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Errors:\t%s\n", formatCounts(s.Errors))
+	_ = w.Flush()
+}
+
+func formatCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "none"
+	}
+	res := ""
+	for i, k := range slices.Sorted(maps.Keys(counts)) {
+		if i > 0 {
+			res += " "
+		}
+		res += fmt.Sprintf("%s=%d", k, counts[k])
+	}
+	return res
+}
@@ -0,0 +1,152 @@
+package xdsreplay
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	discovery "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	"github.com/spf13/pflag"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"istio.io/istio/pkg/log"
+
+	"github.com/howardjohn/pilot-load/adsc"
+	"github.com/howardjohn/pilot-load/pkg/flag"
+	"github.com/howardjohn/pilot-load/pkg/simulation/model"
+)
+
+type Config struct {
+	File   string
+	Listen string
+	Speed  float64
+}
+
+func Command(f *pflag.FlagSet) flag.Command {
+	cfg := Config{
+		Listen: ":15010",
+		Speed:  1,
+	}
+
+	flag.RegisterShort(f, &cfg.File, "file", "f", "recording to replay, as written by --record-dir").Required()
+	flag.Register(f, &cfg.Listen, "listen", "address to serve ADS on")
+	flag.Register(f, &cfg.Speed, "speed", "playback speed multiplier. If 0, responses are sent as fast as possible")
+
+	return flag.Command{
+		Name:        "xds-replay",
+		Description: "serve a recorded XDS stream as a fake ADS server",
+		Details: "Each client connecting is sent the recorded responses, in order and with the recorded timing. " +
+			"Requests from the client are ignored, other than waiting for the first one.",
+		Build: func(args *model.Args) (model.DebuggableSimulation, error) {
+			return &Simulation{Spec: cfg}, nil
+		},
+	}
+}
+
+type Simulation struct {
+	discovery.UnimplementedAggregatedDiscoveryServiceServer
+
+	Spec Config
+
+	sotw   []adsc.Record
+	delta  []adsc.Record
+	server *grpc.Server
+}
+
+var (
+	_ model.Simulation                           = &Simulation{}
+	_ discovery.AggregatedDiscoveryServiceServer = &Simulation{}
+)
+
+func (s *Simulation) GetConfig() any {
+	return s.Spec
+}
+
+func (s *Simulation) Run(ctx model.Context) error {
+	records, err := adsc.ReadRecording(s.Spec.File)
+	if err != nil {
+		return fmt.Errorf("failed to read recording: %v", err)
+	}
+	for _, r := range records {
+		if r.SotW != nil {
+			s.sotw = append(s.sotw, r)
+		} else {
+			s.delta = append(s.delta, r)
+		}
+	}
+	log.Infof("loaded %d SotW and %d delta responses from %v", len(s.sotw), len(s.delta), s.Spec.File)
+
+	l, err := net.Listen("tcp", s.Spec.Listen)
+	if err != nil {
+		return err
+	}
+	s.server = grpc.NewServer()
+	discovery.RegisterAggregatedDiscoveryServiceServer(s.server, s)
+	go func() {
+		if err := s.server.Serve(l); err != nil {
+			log.Errorf("server failed: %v", err)
+		}
+	}()
+	log.Infof("serving ADS on %v", l.Addr())
+	return nil
+}
+
+func (s *Simulation) Cleanup(ctx model.Context) error {
+	if s.server != nil {
+		s.server.Stop()
+	}
+	return nil
+}
+
+func (s *Simulation) StreamAggregatedResources(stream discovery.AggregatedDiscoveryService_StreamAggregatedResourcesServer) error {
+	return s.replay(stream.Context(), s.sotw, func() error {
+		_, err := stream.Recv()
+		return err
+	}, func(r adsc.Record) error {
+		return stream.Send(r.SotW)
+	})
+}
+
+func (s *Simulation) DeltaAggregatedResources(stream discovery.AggregatedDiscoveryService_DeltaAggregatedResourcesServer) error {
+	return s.replay(stream.Context(), s.delta, func() error {
+		_, err := stream.Recv()
+		return err
+	}, func(r adsc.Record) error {
+		return stream.Send(r.Delta)
+	})
+}
+
+// replay sends the records, with the recorded timing, once the client sends its first request. The stream is then
+// kept open until the client disconnects.
+func (s *Simulation) replay(ctx context.Context, records []adsc.Record, recv func() error, send func(adsc.Record) error) error {
+	if len(records) == 0 {
+		return status.Error(codes.Unimplemented, "recording has no responses for this protocol")
+	}
+	if err := recv(); err != nil {
+		return err
+	}
+	// Requests are not used, but must be read so the client is not blocked by flow control
+	go func() {
+		for recv() == nil {
+		}
+	}()
+	t0 := time.Now()
+	for _, r := range records {
+		if s.Spec.Speed > 0 {
+			at := time.Duration(float64(r.Time.Sub(records[0].Time)) / s.Spec.Speed)
+			select {
+			case <-time.After(time.Until(t0.Add(at))):
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		if err := send(r); err != nil {
+			return err
+		}
+	}
+	log.Infof("replayed %d responses in %v", len(records), time.Since(t0))
+	<-ctx.Done()
+	return nil
+}